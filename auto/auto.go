@@ -0,0 +1,421 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Package auto implements a bitmap that picks its own encoding. It starts out as an EWAH bitmap
+// and switches to an uncompressed bitset when the compressed form stops paying for itself, or when
+// bits are set out of order, which EWAH does not support.
+package auto
+
+import (
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/ewah"
+	"iter"
+)
+
+// minWords is the uncompressed size, in words, below which Auto does not switch encodings based on
+// density. Small bitmaps are cheap either way, and EWAH always needs at least one marker word.
+const minWords int64 = 16
+
+type Auto struct {
+	// bm is either an EWAH bitmap or a bitset
+	bm bitmap.Bitmap
+}
+
+var _ bitmap.Bitmap = (*Auto)(nil)
+var _ bitmap.Cardinalities = (*Auto)(nil)
+
+func New() bitmap.Bitmap {
+	return &Auto{
+		bm: ewah.New(),
+	}
+}
+
+// Set sets the bit at position i to true (1). Unlike EWAH bitmaps, bits can be set in any order.
+// Setting a bit before the end of an EWAH bitmap converts it to a bitset.
+func (this *Auto) Set(i int64) bitmap.Bitmap {
+	if i < 0 {
+		return nil
+	}
+
+	if isEwah(this.bm) && i < this.bm.Size() {
+		this.bm = toBitset(this.bm)
+	}
+
+	if this.bm.Set(i) == nil {
+		return nil
+	}
+
+	if isEwah(this.bm) && tooBig(this.bm) {
+		this.bm = toBitset(this.bm)
+	}
+
+	return this
+}
+
+func (this *Auto) Get(i int64) bool {
+	return this.bm.Get(i)
+}
+
+func (this *Auto) Size() int64 {
+	return this.bm.Size()
+}
+
+func (this *Auto) Reset() {
+	this.bm = ewah.New()
+}
+
+func (this *Auto) Clone() bitmap.Bitmap {
+	return &Auto{
+		bm: this.bm.Clone(),
+	}
+}
+
+func (this *Auto) Copy(other bitmap.Bitmap) bitmap.Bitmap {
+	if other == nil {
+		return nil
+	}
+
+	this.bm = unwrap(other).Clone()
+	return this
+}
+
+func (this *Auto) Equal(other bitmap.Bitmap) bool {
+	if other == nil {
+		return false
+	}
+
+	return this.bm.Equal(this.match(unwrap(other)))
+}
+
+// SameBits returns true if other has the same bits set, whatever its size or encoding.
+func (this *Auto) SameBits(other bitmap.Bitmap) bool {
+	if other == nil {
+		return false
+	}
+
+	bm, ok := this.bm.(interface {
+		SameBits(bitmap.Bitmap) bool
+	})
+	if !ok {
+		return false
+	}
+
+	return bm.SameBits(this.match(unwrap(other)))
+}
+
+// Hash returns a hash of the positions of the bits set, which doesn't depend on the encoding.
+func (this *Auto) Hash() uint64 {
+	return bitmap.Hash(this.bm)
+}
+
+func (this *Auto) Cardinality() int64 {
+	return this.bm.Cardinality()
+}
+
+func (this *Auto) IsSubsetOf(other bitmap.Bitmap) bool {
+	return this.bm.IsSubsetOf(this.match(unwrap(other)))
+}
+
+func (this *Auto) IsSupersetOf(other bitmap.Bitmap) bool {
+	return this.bm.IsSupersetOf(this.match(unwrap(other)))
+}
+
+func (this *Auto) Intersects(other bitmap.Bitmap) bool {
+	return this.bm.Intersects(this.match(unwrap(other)))
+}
+
+func (this *Auto) IsDisjoint(other bitmap.Bitmap) bool {
+	return this.bm.IsDisjoint(this.match(unwrap(other)))
+}
+
+func (this *Auto) AndCardinality(other bitmap.Bitmap) int64 {
+	return this.cardinalities().AndCardinality(this.match(unwrap(other)))
+}
+
+func (this *Auto) OrCardinality(other bitmap.Bitmap) int64 {
+	return this.cardinalities().OrCardinality(this.match(unwrap(other)))
+}
+
+func (this *Auto) AndNotCardinality(other bitmap.Bitmap) int64 {
+	return this.cardinalities().AndNotCardinality(this.match(unwrap(other)))
+}
+
+func (this *Auto) XorCardinality(other bitmap.Bitmap) int64 {
+	return this.cardinalities().XorCardinality(this.match(unwrap(other)))
+}
+
+func (this *Auto) And(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.result(this.bm.And(this.matchAll(a)...))
+}
+
+func (this *Auto) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.result(this.bm.Or(this.matchAll(a)...))
+}
+
+func (this *Auto) AndNot(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.result(this.bm.AndNot(this.matchAll(a)...))
+}
+
+func (this *Auto) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.result(this.bm.Xor(this.matchAll(a)...))
+}
+
+// Not flips every bit up to Size(), and re-evaluates the encoding since negating a sparse bitmap
+// makes it dense.
+func (this *Auto) Not() bitmap.Bitmap {
+	if this.bm.Not() == nil {
+		return nil
+	}
+
+	this.Optimize()
+
+	return this
+}
+
+// ShiftLeft returns a new bitmap with every bit moved n positions up, see ewah.Ewah.ShiftLeft and
+// bitset.Bitset.ShiftLeft.
+func (this *Auto) ShiftLeft(n int64) bitmap.Bitmap {
+	bm, ok := this.bm.(shifter)
+	if !ok {
+		return nil
+	}
+
+	return this.result(bm.ShiftLeft(n))
+}
+
+// ShiftRight returns a new bitmap with every bit moved n positions down. Bits that would move below
+// 0 are dropped.
+func (this *Auto) ShiftRight(n int64) bitmap.Bitmap {
+	bm, ok := this.bm.(shifter)
+	if !ok {
+		return nil
+	}
+
+	return this.result(bm.ShiftRight(n))
+}
+
+// Slice returns a new bitmap with the bits in [start, end), moved down by start so bit start becomes
+// bit 0.
+func (this *Auto) Slice(start, end int64) bitmap.Bitmap {
+	bm, ok := this.bm.(slicer)
+	if !ok {
+		return nil
+	}
+
+	return this.result(bm.Slice(start, end))
+}
+
+// Range returns a new bitmap with only the bits in [start, end), which stay where they are.
+func (this *Auto) Range(start, end int64) bitmap.Bitmap {
+	bm, ok := this.bm.(slicer)
+	if !ok {
+		return nil
+	}
+
+	return this.result(bm.Range(start, end))
+}
+
+// All returns an iterator over the positions of the bits set, in ascending order.
+func (this *Auto) All() iter.Seq[int64] {
+	return bitmap.All(this.bm)
+}
+
+// Backward returns an iterator over the positions of the bits set, in descending order.
+func (this *Auto) Backward() iter.Seq[int64] {
+	return bitmap.Backward(this.bm)
+}
+
+// Runs returns an iterator over the runs of consecutive bits set, as the position of the first bit
+// and the number of bits. It returns nil if the bitmap holding the bits cannot iterate over runs.
+func (this *Auto) Runs() iter.Seq2[int64, int64] {
+	bm, ok := this.bm.(iterable)
+	if !ok {
+		return nil
+	}
+
+	return bm.Runs()
+}
+
+// Append adds the bits of other after the end of this bitmap, so bit i of other becomes bit Size()+i.
+func (this *Auto) Append(other bitmap.Bitmap) bitmap.Bitmap {
+	a, ok := this.bm.(interface {
+		Append(bitmap.Bitmap) bitmap.Bitmap
+	})
+	if !ok {
+		return nil
+	}
+
+	bm := a.Append(this.match(unwrap(other)))
+	if bm == nil {
+		return nil
+	}
+
+	this.bm = bm
+	if isEwah(this.bm) && tooBig(this.bm) {
+		this.bm = toBitset(this.bm)
+	}
+
+	return this
+}
+
+// Stats reports how the bitmap is currently stored, or zero Stats if the bitmap holding the bits
+// cannot report them.
+func (this *Auto) Stats() bitmap.Stats {
+	bm, ok := this.bm.(interface {
+		Stats() bitmap.Stats
+	})
+	if !ok {
+		return bitmap.Stats{}
+	}
+
+	return bm.Stats()
+}
+
+// Unwrap returns the bitmap that currently holds the bits, either an *ewah.Ewah or a
+// *bitset.Bitset. The returned bitmap is shared with Auto, and may be replaced by the next Set.
+func (this *Auto) Unwrap() bitmap.Bitmap {
+	return this.bm
+}
+
+// IsCompressed returns true if the bits are currently stored as an EWAH bitmap.
+func (this *Auto) IsCompressed() bool {
+	return isEwah(this.bm)
+}
+
+// Optimize re-evaluates the encoding. An EWAH bitmap that takes more space than a bitset becomes a
+// bitset, and a bitset that's sparse enough to compress well becomes an EWAH bitmap again. Results
+// of And, Or, AndNot and Xor are optimized automatically.
+func (this *Auto) Optimize() {
+	if isEwah(this.bm) {
+		if tooBig(this.bm) {
+			this.bm = toBitset(this.bm)
+		}
+
+		return
+	}
+
+	// Every set bit costs EWAH at most a marker word and a literal word, so this is an upper bound
+	// of the compressed size.
+	words := (this.bm.Size() + 63) / 64
+	if words >= minWords && 2*this.bm.Cardinality()+1 < words {
+		this.bm = toEwah(this.bm)
+	}
+}
+
+// cardinalities returns the bitmap holding the bits, which can count the results of bitwise
+// operations without creating them
+func (this *Auto) cardinalities() bitmap.Cardinalities {
+	return this.bm.(bitmap.Cardinalities)
+}
+
+// result wraps the result of a bitwise operation and picks the best encoding for it
+func (this *Auto) result(bm bitmap.Bitmap) bitmap.Bitmap {
+	if bm == nil {
+		return nil
+	}
+
+	ans := &Auto{bm: bm}
+	ans.Optimize()
+
+	return ans
+}
+
+// match returns other in the same encoding as this bitmap, converting it if needed. A nil bitmap
+// stays nil.
+func (this *Auto) match(other bitmap.Bitmap) bitmap.Bitmap {
+	if other == nil || isEwah(this.bm) == isEwah(other) {
+		return other
+	}
+
+	if isEwah(this.bm) {
+		return toEwah(other)
+	}
+
+	return toBitset(other)
+}
+
+func (this *Auto) matchAll(a []bitmap.Bitmap) []bitmap.Bitmap {
+	m := make([]bitmap.Bitmap, len(a))
+	for i, v := range a {
+		m[i] = this.match(unwrap(v))
+	}
+
+	return m
+}
+
+type shifter interface {
+	ShiftLeft(int64) bitmap.Bitmap
+	ShiftRight(int64) bitmap.Bitmap
+}
+
+type slicer interface {
+	Slice(int64, int64) bitmap.Bitmap
+	Range(int64, int64) bitmap.Bitmap
+}
+
+type iterable interface {
+	Runs() iter.Seq2[int64, int64]
+}
+
+// unwrap returns the bitmap held by an Auto bitmap, or the result of a lazy expression, or the
+// bitmap itself otherwise
+func unwrap(b bitmap.Bitmap) bitmap.Bitmap {
+	switch bm := b.(type) {
+	case *Auto:
+		return bm.bm
+	case *bitmap.Expr:
+		return unwrap(bm.Eval())
+	}
+
+	return b
+}
+
+func isEwah(b bitmap.Bitmap) bool {
+	switch b.(type) {
+	case *ewah.Ewah, *ewah.Frozen:
+		return true
+	}
+
+	return false
+}
+
+// tooBig returns true if the EWAH bitmap takes more space than the same bits uncompressed
+func tooBig(b bitmap.Bitmap) bool {
+	bm, ok := b.(interface {
+		SizeInWords() int64
+	})
+	if !ok {
+		return false
+	}
+
+	words := (b.Size() + 63) / 64
+	return words >= minWords && bm.SizeInWords() > words
+}
+
+// toBitset converts b to a bitset, word by word if it's an EWAH bitmap
+func toBitset(b bitmap.Bitmap) bitmap.Bitmap {
+	switch bm := b.(type) {
+	case *ewah.Ewah:
+		return bitset.FromEwah(bm)
+	case *ewah.Frozen:
+		return bitset.FromEwah(bm)
+	}
+
+	return bitmap.Convert(b, bitset.New)
+}
+
+// toEwah converts b to an EWAH bitmap, word by word if it's a bitset
+func toEwah(b bitmap.Bitmap) bitmap.Bitmap {
+	switch bm := b.(type) {
+	case *bitset.Bitset:
+		return ewah.FromBitset(bm)
+	case *bitset.Frozen:
+		return ewah.FromWords(bm.Words(), bm.Size())
+	}
+
+	return bitmap.Convert(b, ewah.New)
+}
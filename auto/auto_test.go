@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package auto
+
+import (
+	"github.com/reducedb/bitmap/bitmaptest"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/ewah"
+	"testing"
+)
+
+func TestSetOutOfOrder(t *testing.T) {
+	bm := New().(*Auto)
+
+	bm.Set(100)
+	bm.Set(15000)
+
+	if !bm.IsCompressed() {
+		t.Fatal("Bitmap should still be compressed")
+	}
+
+	if bm.Set(10) == nil {
+		t.Fatal("Problem setting bit 10 out of order")
+	}
+
+	if bm.IsCompressed() {
+		t.Fatal("Bitmap should have switched to a bitset")
+	}
+
+	nums := []int64{10, 100, 15000}
+	for i := range nums {
+		if !bm.Get(nums[i]) {
+			t.Fatalf("Get(%d) failed, should be set\n", nums[i])
+		}
+	}
+
+	if bm.Cardinality() != 3 {
+		t.Fatalf("Cardinality %d != 3", bm.Cardinality())
+	}
+}
+
+func TestSetDense(t *testing.T) {
+	bm := New().(*Auto)
+
+	// Every other bit is set, so there are no empty words for EWAH to compress
+	for i := int64(0); i < 64*100; i += 2 {
+		bm.Set(i)
+	}
+
+	if bm.IsCompressed() {
+		t.Fatalf("Dense bitmap should have switched to a bitset: %v", bm.Stats())
+	}
+
+	if bm.Cardinality() != 64*50 {
+		t.Fatalf("Cardinality %d != %d", bm.Cardinality(), 64*50)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	bm := New().(*Auto)
+	bm.Set(100000)
+	bm.Set(10)
+
+	if bm.IsCompressed() {
+		t.Fatal("Bitmap should have switched to a bitset")
+	}
+
+	bm.Optimize()
+
+	if !bm.IsCompressed() {
+		t.Fatalf("Sparse bitmap should have switched back to EWAH: %v", bm.Stats())
+	}
+
+	if !bm.Get(10) || !bm.Get(100000) || bm.Cardinality() != 2 {
+		t.Fatal("Bits were lost switching encodings")
+	}
+}
+
+func TestNotOptimize(t *testing.T) {
+	bm := New().(*Auto)
+
+	// Setting bits out of order makes it a bitset, which is dense until it's negated
+	for i := int64(64*100 - 1); i >= 0; i-- {
+		if i != 10 && i != 5000 {
+			bm.Set(i)
+		}
+	}
+
+	if bm.IsCompressed() {
+		t.Fatal("Bitmap should have switched to a bitset")
+	}
+
+	if bm.Not() == nil || !bm.IsCompressed() {
+		t.Fatalf("Negated dense bitset should have switched to EWAH: %v", bm.Stats())
+	}
+
+	if !bm.Get(10) || !bm.Get(5000) || bm.Cardinality() != 2 {
+		t.Fatal("Bits were lost switching encodings")
+	}
+}
+
+func TestWrapFrozen(t *testing.T) {
+	e := ewah.New()
+	for i := int64(0); i < 64*100; i += 2 {
+		e.Set(i)
+	}
+
+	// A frozen EWAH bitmap can end up inside an Auto bitmap, and must not make it panic
+	bm := &Auto{bm: e.(*ewah.Ewah).Freeze()}
+	bm.Optimize()
+
+	if bm.IsCompressed() || bm.Cardinality() != 64*50 {
+		t.Fatalf("Dense frozen bitmap should have switched to a bitset: %v", bm.Stats())
+	}
+
+	bm = &Auto{bm: e.(*ewah.Ewah).Freeze()}
+	if bm.ShiftLeft(1).Cardinality() != 64*50 || bm.Range(0, 64).Cardinality() != 32 {
+		t.Fatal("Shift or Range of a frozen bitmap failed")
+	}
+
+	n := 0
+	for range bm.All() {
+		n++
+	}
+	for range bm.Runs() {
+		n++
+	}
+	if n != 2*64*50 || bm.Stats().SizeInWords == 0 {
+		t.Fatalf("Iterating over a frozen bitmap returned %d positions and runs", n)
+	}
+}
+
+func TestWrapFrozenBitset(t *testing.T) {
+	bs := bitset.New().Set(10).Set(100000)
+
+	// A sparse frozen bitset is converted to EWAH word by word, like a bitset
+	bm := &Auto{bm: bs.(*bitset.Bitset).Freeze()}
+	bm.Optimize()
+
+	if !bm.IsCompressed() || bm.Cardinality() != 2 || !bm.Get(10) || !bm.Get(100000) {
+		t.Fatalf("Sparse frozen bitset should have switched to EWAH: %v", bm.Stats())
+	}
+
+	if _, ok := toEwah(bs.(*bitset.Bitset).Freeze()).(*ewah.Ewah); !ok {
+		t.Fatal("Frozen bitset should convert to an EWAH bitmap")
+	}
+
+	if bm.Copy(nil) != nil || bm.Cardinality() != 2 {
+		t.Fatal("Copy(nil) should fail and leave the bitmap as it was")
+	}
+}
+
+func TestMixedEncodings(t *testing.T) {
+	bm := New().(*Auto)
+	bm.Set(10)
+	bm.Set(100)
+	bm.Set(15000)
+
+	bm2 := bitset.New()
+	bm2.Set(15000)
+	bm2.Set(100)
+
+	bm3 := ewah.New()
+	bm3.Set(100)
+
+	if c := bm.And(bm2).Cardinality(); c != 2 {
+		t.Fatalf("And with bitset: cardinality %d != 2", c)
+	}
+
+	if c := bm.AndNot(bm2, bm3).Cardinality(); c != 1 {
+		t.Fatalf("AndNot with bitset and EWAH: cardinality %d != 1", c)
+	}
+
+	if c := bm.Xor(bm3.(*ewah.Ewah).Freeze()).Cardinality(); c != 2 {
+		t.Fatalf("Xor with frozen EWAH: cardinality %d != 2", c)
+	}
+
+	bm4 := New().(*Auto)
+	bm4.Set(15000)
+	bm4.Set(11)
+
+	if c := bm.Or(bm4).Cardinality(); c != 4 {
+		t.Fatalf("Or of compressed and uncompressed bitmaps: cardinality %d != 4", c)
+	}
+}
+
+func TestSameBits(t *testing.T) {
+	bm := New().(*Auto)
+	bm.Set(100)
+	bm.Set(10)
+
+	bm2 := ewah.New()
+	bm2.Set(10)
+	bm2.Set(100)
+	bm2 = bm2.Or(ewah.New().Set(20000).AndNot(ewah.New().Set(20000)))
+
+	if bm.IsCompressed() || bm.Equal(bm2) {
+		t.Fatal("Bitmaps in different encodings and sizes should not be equal")
+	}
+
+	if !bm.SameBits(bm2) {
+		t.Fatal("Bitmaps with the same bits set should have the same bits")
+	}
+
+	if bm.Hash() != bm2.(*ewah.Ewah).Hash() {
+		t.Fatal("Bitmaps with the same bits set should have the same hash")
+	}
+
+	bm.Set(30000)
+	if bm.SameBits(bm2) || bm.Hash() == bm2.(*ewah.Ewah).Hash() {
+		t.Fatal("Bitmaps with different bits set should not have the same bits or hash")
+	}
+}
+
+func TestShift(t *testing.T) {
+	bm := New().(*Auto)
+	bm.Set(100)
+	bm.Set(10)
+
+	left := bm.ShiftLeft(1000)
+	if !left.Get(1010) || !left.Get(1100) || left.Cardinality() != 2 || left.Size() != 1101 {
+		t.Fatalf("ShiftLeft moved the bits to the wrong place: %v", left.(*Auto).Stats())
+	}
+
+	if !left.(*Auto).IsCompressed() {
+		t.Fatal("Sparse result of a shift should be compressed")
+	}
+
+	right := left.(*Auto).ShiftRight(1005)
+	if !right.Get(5) || !right.Get(95) || right.Cardinality() != 2 || right.Size() != 96 {
+		t.Fatal("ShiftRight moved the bits to the wrong place")
+	}
+}
+
+func TestAppend(t *testing.T) {
+	bm := New().(*Auto)
+	bm.Set(10)
+
+	bm2 := bitset.New().Set(5)
+	if bm.Append(bm2) == nil || bm.Size() != 17 || !bm.Get(16) || !bm.Get(10) || bm.Cardinality() != 2 {
+		t.Fatal("Append put the bits in the wrong place")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	bitmaptest.Run(t, New, bitmaptest.Options{})
+}
+
+func BenchmarkOps(b *testing.B) {
+	bitmaptest.Benchmark(b, New)
+}
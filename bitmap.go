@@ -6,6 +6,13 @@
 
 package bitmap
 
+import (
+	"errors"
+)
+
+// ErrFrozen is the panic value used when a frozen bitmap is modified.
+var ErrFrozen = errors.New("bitmap: frozen bitmaps cannot be modified")
+
 type Bitmap interface {
 	Set(int64) Bitmap
 	Get(int64) bool
@@ -17,6 +24,11 @@ type Bitmap interface {
 
 	Cardinality() int64
 
+	IsSubsetOf(Bitmap) bool
+	IsSupersetOf(Bitmap) bool
+	Intersects(Bitmap) bool
+	IsDisjoint(Bitmap) bool
+
 	And(...Bitmap) Bitmap
 	Or(...Bitmap) Bitmap
 	AndNot(...Bitmap) Bitmap
@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Package bitmapindex implements equality-encoded bitmap indexes for categorical columns. The index
+// keeps one bitmap per distinct value of the column, with a bit set for every row that has the value.
+// Rows are appended in order, which matches the ascending order EWAH bitmaps need for Set:
+//
+//	idx := bitmapindex.New()
+//	idx.Append("red")
+//	idx.Append("blue")
+//	idx.Append("red")
+//	rows := idx.In("red", "green")
+//
+// Ordinal indexes use range or interval encoding instead, for columns queried by ranges of values.
+package bitmapindex
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/ewah"
+	"sort"
+)
+
+type Index struct {
+	factory func() bitmap.Bitmap
+
+	// values maps each distinct value to the rows that have it
+	values map[string]bitmap.Bitmap
+
+	// all has a bit set for every row, to answer NotIn
+	all bitmap.Bitmap
+
+	// rows is the number of rows appended so far
+	rows int64
+}
+
+// New returns an empty index of EWAH bitmaps.
+func New() *Index {
+	return NewWithFactory(ewah.New)
+}
+
+// NewWithFactory returns an empty index whose bitmaps are created by factory. To be persisted with
+// MarshalBinary and UnmarshalBinary, the bitmaps have to implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler.
+func NewWithFactory(factory func() bitmap.Bitmap) *Index {
+	return &Index{
+		factory: factory,
+		values:  make(map[string]bitmap.Bitmap),
+		all:     factory(),
+	}
+}
+
+// Append adds a row with the given value at the end of the index.
+func (this *Index) Append(value string) error {
+	bm, ok := this.values[value]
+	if !ok {
+		bm = this.factory()
+		this.values[value] = bm
+	}
+
+	if bm.Set(this.rows) == nil || this.all.Set(this.rows) == nil {
+		return fmt.Errorf("bitmapindex/Append: cannot set row %d", this.rows)
+	}
+
+	this.rows += 1
+	return nil
+}
+
+// Rows returns the number of rows in the index.
+func (this *Index) Rows() int64 {
+	return this.rows
+}
+
+// Values returns the distinct values in the index, sorted.
+func (this *Index) Values() []string {
+	values := make([]string, 0, len(this.values))
+	for v := range this.values {
+		values = append(values, v)
+	}
+
+	sort.Strings(values)
+	return values
+}
+
+// Eq returns the rows whose value is value.
+func (this *Index) Eq(value string) bitmap.Bitmap {
+	if bm, ok := this.values[value]; ok {
+		return bm.Clone()
+	}
+
+	return this.factory()
+}
+
+// In returns the rows whose value is one of values.
+func (this *Index) In(values ...string) bitmap.Bitmap {
+	a := make([]bitmap.Bitmap, 0, len(values))
+	for _, v := range values {
+		if bm, ok := this.values[v]; ok {
+			a = append(a, bm)
+		}
+	}
+
+	if len(a) == 0 {
+		return this.factory()
+	}
+
+	return this.factory().Or(a...)
+}
+
+// NotIn returns the rows whose value is none of values.
+func (this *Index) NotIn(values ...string) bitmap.Bitmap {
+	return this.all.AndNot(this.In(values...))
+}
+
+// MarshalBinary encodes the index as the number of rows and the number of values, followed by each
+// value and the encoding of its bitmap, in sorted order. Lengths and counts are uvarints.
+func (this *Index) MarshalBinary() ([]byte, error) {
+	data := binary.AppendUvarint(nil, uint64(this.rows))
+	data = binary.AppendUvarint(data, uint64(len(this.values)))
+
+	for _, v := range this.Values() {
+		m, ok := this.values[v].(encoding.BinaryMarshaler)
+		if !ok {
+			return nil, errors.New("bitmapindex/MarshalBinary: bitmaps do not implement encoding.BinaryMarshaler")
+		}
+
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		data = binary.AppendUvarint(data, uint64(len(v)))
+		data = append(data, v...)
+		data = binary.AppendUvarint(data, uint64(len(b)))
+		data = append(data, b...)
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes an index encoded by MarshalBinary, replacing the index's contents. The
+// bitmaps are created with the index's factory.
+func (this *Index) UnmarshalBinary(data []byte) error {
+	if this.factory == nil {
+		this.factory = ewah.New
+	}
+
+	rows, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]bitmap.Bitmap)
+	all, total := this.factory(), int64(0)
+
+	for i := uint64(0); i < n; i++ {
+		var v, b []byte
+
+		if v, data, err = readBytes(data); err != nil {
+			return err
+		}
+
+		if b, data, err = readBytes(data); err != nil {
+			return err
+		}
+
+		bm := this.factory()
+		u, ok := bm.(encoding.BinaryUnmarshaler)
+		if !ok {
+			return errors.New("bitmapindex/UnmarshalBinary: bitmaps do not implement encoding.BinaryUnmarshaler")
+		}
+
+		if err := u.UnmarshalBinary(b); err != nil {
+			return err
+		}
+
+		if _, ok := values[string(v)]; ok {
+			return fmt.Errorf("bitmapindex/UnmarshalBinary: duplicate value %q", v)
+		}
+
+		// Rows are appended after the last one, so no bitmap can go past it
+		if bm.Size() > int64(rows) {
+			return fmt.Errorf("bitmapindex/UnmarshalBinary: value %q has %d rows, but the index only has %d", v, bm.Size(), rows)
+		}
+
+		values[string(v)] = bm
+		all = all.Or(bm)
+		total += bm.Cardinality()
+	}
+
+	if len(data) != 0 {
+		return fmt.Errorf("bitmapindex/UnmarshalBinary: %d bytes left over", len(data))
+	}
+
+	// Every row has exactly one value, so the rows of all the values are all the rows
+	if all.Size() != int64(rows) || all.Cardinality() != int64(rows) || total != int64(rows) {
+		return fmt.Errorf("bitmapindex/UnmarshalBinary: values cover %d rows, but the index has %d", all.Cardinality(), rows)
+	}
+
+	this.values, this.all, this.rows = values, all, int64(rows)
+	return nil
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 || v > uint64(1<<62) {
+		return 0, nil, errors.New("bitmapindex/UnmarshalBinary: invalid length")
+	}
+
+	return v, data[n:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if n > uint64(len(data)) {
+		return nil, nil, errors.New("bitmapindex/UnmarshalBinary: data is truncated")
+	}
+
+	return data[:n], data[n:], nil
+}
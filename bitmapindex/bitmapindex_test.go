@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmapindex
+
+import (
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+	"math/rand"
+	"testing"
+)
+
+var colors = []string{"red", "green", "blue", "yellow", "black"}
+
+func newColumn(t *testing.T, idx *Index, n int) []string {
+	r := rand.New(rand.NewSource(1))
+	column := make([]string, n)
+
+	for i := range column {
+		// Skew the values so some are much more common than others
+		column[i] = colors[r.Intn(len(colors))*r.Intn(2)]
+		if err := idx.Append(column[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return column
+}
+
+func checkRows(t *testing.T, name string, bm bitmap.Bitmap, column []string, f func(string) bool) {
+	n := int64(0)
+	for row, v := range column {
+		if f(v) != bm.Get(int64(row)) {
+			t.Fatalf("%s: row %d with value %s is %t", name, row, v, bm.Get(int64(row)))
+		}
+
+		if f(v) {
+			n += 1
+		}
+	}
+
+	if bm.Cardinality() != n {
+		t.Fatalf("%s: cardinality %d != %d", name, bm.Cardinality(), n)
+	}
+}
+
+func checkQueries(t *testing.T, idx *Index, column []string) {
+	if idx.Rows() != int64(len(column)) {
+		t.Fatalf("Rows %d != %d", idx.Rows(), len(column))
+	}
+
+	checkRows(t, "Eq", idx.Eq("red"), column, func(v string) bool { return v == "red" })
+	checkRows(t, "Eq missing", idx.Eq("purple"), column, func(v string) bool { return false })
+	checkRows(t, "In", idx.In("green", "black", "purple"), column, func(v string) bool { return v == "green" || v == "black" })
+	checkRows(t, "In none", idx.In(), column, func(v string) bool { return false })
+	checkRows(t, "NotIn", idx.NotIn("red", "blue"), column, func(v string) bool { return v != "red" && v != "blue" })
+	checkRows(t, "NotIn none", idx.NotIn(), column, func(v string) bool { return true })
+}
+
+func TestQueries(t *testing.T) {
+	idx := New()
+	column := newColumn(t, idx, 10000)
+	checkQueries(t, idx, column)
+
+	if v := idx.Values(); len(v) != len(colors) || v[0] != "black" || v[4] != "yellow" {
+		t.Fatalf("Values %v should be all the colors, sorted", v)
+	}
+
+	idx = NewWithFactory(bitset.New)
+	checkQueries(t, idx, newColumn(t, idx, 1000))
+}
+
+func TestMarshal(t *testing.T) {
+	idx := New()
+	column := newColumn(t, idx, 10000)
+
+	data, err := idx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx2 := New()
+	if err := idx2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	checkQueries(t, idx2, column)
+
+	// Rows can still be appended after loading
+	column = append(column, "red", "white")
+	idx2.Append("red")
+	idx2.Append("white")
+	checkQueries(t, idx2, column)
+
+	for _, n := range []int{0, 1, len(data) / 2, len(data) - 1} {
+		if idx2.UnmarshalBinary(data[:n]) == nil {
+			t.Fatalf("Unmarshaling %d of %d bytes should fail", n, len(data))
+		}
+	}
+
+	// A failed unmarshal leaves the index as it was
+	checkQueries(t, idx2, column)
+}
+
+func TestOrdinal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{1, 2, 3, 10, 11, 50} {
+		for _, encoding := range []Encoding{RangeEncoding, IntervalEncoding} {
+			idx := NewOrdinal(n, encoding)
+			column := make([]int, 2000)
+
+			for i := range column {
+				column[i] = r.Intn(n)
+				if err := idx.Append(column[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if idx.Append(n) == nil || idx.Append(-1) == nil {
+				t.Fatalf("Appending a value outside of the domain of %d values should fail", n)
+			}
+
+			for lo := -1; lo <= n; lo++ {
+				for hi := lo - 1; hi <= n; hi++ {
+					name := fmt.Sprintf("n %d, encoding %d, Between(%d, %d)", n, encoding, lo, hi)
+					checkOrdinal(t, name, idx.Between(lo, hi), column, func(v int) bool { return v >= lo && v <= hi })
+				}
+
+				checkOrdinal(t, "Eq", idx.Eq(lo), column, func(v int) bool { return v == lo })
+				checkOrdinal(t, "Lt", idx.Lt(lo), column, func(v int) bool { return v < lo })
+				checkOrdinal(t, "Le", idx.Le(lo), column, func(v int) bool { return v <= lo })
+				checkOrdinal(t, "Gt", idx.Gt(lo), column, func(v int) bool { return v > lo })
+				checkOrdinal(t, "Ge", idx.Ge(lo), column, func(v int) bool { return v >= lo })
+			}
+		}
+	}
+}
+
+func checkOrdinal(t *testing.T, name string, bm bitmap.Bitmap, column []int, f func(int) bool) {
+	n := int64(0)
+	for row, v := range column {
+		if f(v) != bm.Get(int64(row)) {
+			t.Fatalf("%s: row %d with value %d is %t", name, row, v, bm.Get(int64(row)))
+		}
+
+		if f(v) {
+			n += 1
+		}
+	}
+
+	if bm.Cardinality() != n {
+		t.Fatalf("%s: cardinality %d != %d", name, bm.Cardinality(), n)
+	}
+}
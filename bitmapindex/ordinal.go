@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmapindex
+
+import (
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/ewah"
+)
+
+// Encoding is the way an Ordinal index maps values to bitmaps.
+type Encoding int
+
+const (
+	// RangeEncoding keeps one bitmap per value, where bitmap i has the rows with a value <= i. Every
+	// range query reads at most two bitmaps.
+	RangeEncoding Encoding = iota
+
+	// IntervalEncoding keeps about half as many bitmaps as range encoding. With m = ceil(n/2) for n
+	// values, bitmap j has the rows with a value in [j, j+m-1]. Every range query reads at most two
+	// bitmaps.
+	//
+	// Reference: Chan and Ioannidis, An Efficient Bitmap Encoding Scheme for Selection Queries,
+	// SIGMOD 1999.
+	IntervalEncoding
+)
+
+// Ordinal is a bitmap index for a column of ordered values, like dates or priority levels, mapped to
+// the integers 0 to n-1. Equality encoding needs an Or over every value in a range, while range and
+// interval encodings answer any range query with at most two bitmaps and one And, Or or AndNot.
+type Ordinal struct {
+	factory  func() bitmap.Bitmap
+	encoding Encoding
+
+	// n is the number of distinct values, and m the width of the intervals for interval encoding
+	n, m int
+
+	bitmaps []bitmap.Bitmap
+
+	rows int64
+}
+
+// NewOrdinal returns an empty index of EWAH bitmaps for values from 0 to n-1.
+func NewOrdinal(n int, encoding Encoding) *Ordinal {
+	return NewOrdinalWithFactory(n, encoding, ewah.New)
+}
+
+func NewOrdinalWithFactory(n int, encoding Encoding, factory func() bitmap.Bitmap) *Ordinal {
+	if n < 1 {
+		n = 1
+	}
+
+	this := &Ordinal{
+		factory:  factory,
+		encoding: encoding,
+		n:        n,
+		m:        (n + 1) / 2,
+	}
+
+	k := n
+	if encoding == IntervalEncoding {
+		k = n - this.m + 1
+	}
+
+	this.bitmaps = make([]bitmap.Bitmap, k)
+	for i := range this.bitmaps {
+		this.bitmaps[i] = factory()
+	}
+
+	return this
+}
+
+// Append adds a row with the given value at the end of the index.
+func (this *Ordinal) Append(v int) error {
+	if v < 0 || v >= this.n {
+		return fmt.Errorf("bitmapindex/Append: value %d is not between 0 and %d", v, this.n-1)
+	}
+
+	// The bitmaps that have v: from v up for range encoding, and the intervals starting at most m-1
+	// before v for interval encoding
+	lo, hi := v, this.n-1
+	if this.encoding == IntervalEncoding {
+		lo, hi = v-this.m+1, v
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(this.bitmaps)-1 {
+			hi = len(this.bitmaps) - 1
+		}
+	}
+
+	for i := lo; i <= hi; i++ {
+		if this.bitmaps[i].Set(this.rows) == nil {
+			return fmt.Errorf("bitmapindex/Append: cannot set row %d", this.rows)
+		}
+	}
+
+	this.rows += 1
+	return nil
+}
+
+// Rows returns the number of rows in the index.
+func (this *Ordinal) Rows() int64 {
+	return this.rows
+}
+
+func (this *Ordinal) Eq(v int) bitmap.Bitmap {
+	return this.Between(v, v)
+}
+
+func (this *Ordinal) Lt(v int) bitmap.Bitmap {
+	return this.Between(0, v-1)
+}
+
+func (this *Ordinal) Le(v int) bitmap.Bitmap {
+	return this.Between(0, v)
+}
+
+func (this *Ordinal) Gt(v int) bitmap.Bitmap {
+	return this.Between(v+1, this.n-1)
+}
+
+func (this *Ordinal) Ge(v int) bitmap.Bitmap {
+	return this.Between(v, this.n-1)
+}
+
+// Between returns the rows whose value is between lo and hi, inclusive.
+func (this *Ordinal) Between(lo, hi int) bitmap.Bitmap {
+	if lo < 0 {
+		lo = 0
+	}
+
+	if hi > this.n-1 {
+		hi = this.n - 1
+	}
+
+	if lo > hi {
+		return this.factory()
+	}
+
+	if this.encoding == IntervalEncoding {
+		return this.interval(lo, hi)
+	}
+
+	if lo == 0 {
+		return this.bitmaps[hi].Clone()
+	}
+
+	return this.bitmaps[hi].AndNot(this.bitmaps[lo-1])
+}
+
+// interval returns the rows whose value is in [lo, hi] from the interval bitmaps I_j = [j, j+m-1],
+// for j from 0 to n-m.
+func (this *Ordinal) interval(lo, hi int) bitmap.Bitmap {
+	m, last := this.m, len(this.bitmaps)-1
+
+	switch {
+	case hi-lo+1 > m:
+		// Two intervals overlapping or next to each other cover the range
+		return this.bitmaps[lo].Or(this.bitmaps[hi-m+1])
+	case lo <= last && hi-m+1 >= 0:
+		// The range is the overlap of the interval starting at lo and the one ending at hi
+		if lo == hi-m+1 {
+			return this.bitmaps[lo].Clone()
+		}
+		return this.bitmaps[lo].And(this.bitmaps[hi-m+1])
+	case lo <= last:
+		// The range is at the start of the interval starting at lo
+		return this.bitmaps[lo].AndNot(this.bitmaps[hi+1])
+	default:
+		// The range is at the end of the interval ending at hi
+		return this.bitmaps[hi-m+1].AndNot(this.bitmaps[lo-m])
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmaptest
+
+import (
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/datagen"
+	"testing"
+)
+
+var (
+	// BenchSizes are the sizes, in bits, of the bitmaps Benchmark runs on
+	BenchSizes = []int64{10000, 1000000}
+
+	// BenchDistributions are the names of the datagen generators of the bitmaps Benchmark runs on
+	BenchDistributions = []string{"uniform", "clustered"}
+
+	// BenchDensities are the fractions of the bits set in the bitmaps Benchmark runs on
+	BenchDensities = []float64{0.0001, 0.01, 0.1, 0.5}
+
+	// BenchOps are the operations Benchmark times
+	BenchOps = []string{"and", "or", "xor", "andnot", "not", "cardinality", "get"}
+)
+
+// Benchmark times the operations of the implementation created by factory on bitmaps of every size,
+// distribution and density, as sub-benchmarks named like size=10000/dist=uniform/density=0.01/op=and.
+// Every sub-benchmark also reports the compressed size of the bitmaps it runs on, for implementations
+// that have Stats. An implementation's tests just call it with its constructor:
+//
+//	func BenchmarkOps(b *testing.B) {
+//		bitmaptest.Benchmark(b, ewah.New)
+//	}
+func Benchmark(b *testing.B, factory func() bitmap.Bitmap) {
+	for _, size := range BenchSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			for _, dist := range BenchDistributions {
+				b.Run("dist="+dist, func(b *testing.B) {
+					for _, density := range BenchDensities {
+						b.Run(fmt.Sprintf("density=%g", density), func(b *testing.B) {
+							benchmarkOps(b, factory, datagen.Named(dist), size, density)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+func benchmarkOps(b *testing.B, factory func() bitmap.Bitmap, g datagen.Generator, size int64, density float64) {
+	x := datagen.Bitmap(factory, datagen.Generate(g, size, size, density))
+	y := datagen.Bitmap(factory, datagen.Generate(g, size+1, size, density))
+
+	for _, op := range BenchOps {
+		b.Run("op="+op, func(b *testing.B) {
+			f := Op(op, x, y)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f(i)
+			}
+
+			if s, ok := x.(interface{ Stats() bitmap.Stats }); ok {
+				b.ReportMetric(float64(s.Stats().SizeInBytes), "bytes/bitmap")
+			}
+		})
+	}
+}
+
+// Op returns a function that runs one of BenchOps on x, and y for the operations on two bitmaps. Its
+// argument is the iteration number, which get uses as the position to check. Not works on a copy of
+// x, which it flips back and forth. It panics on unknown operations.
+func Op(op string, x, y bitmap.Bitmap) func(int) {
+	switch op {
+	case "and":
+		return func(int) { x.And(y) }
+	case "or":
+		return func(int) { x.Or(y) }
+	case "xor":
+		return func(int) { x.Xor(y) }
+	case "andnot":
+		return func(int) { x.AndNot(y) }
+	case "not":
+		c := x.Clone()
+		return func(int) { c.Not() }
+	case "cardinality":
+		return func(int) { x.Cardinality() }
+	case "get":
+		n := x.Size()
+		if n == 0 {
+			n = 1
+		}
+		return func(i int) { x.Get(int64(i*7919) % n) }
+	}
+
+	panic("bitmaptest: unknown operation " + op)
+}
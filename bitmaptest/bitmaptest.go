@@ -0,0 +1,499 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Package bitmaptest is a conformance suite for bitmap.Bitmap implementations. It runs randomized
+// differential tests of every interface method against a trivial []bool model of the bitmap, with
+// bit positions that favor the edges of 64-bit words and long runs of 0's and 1's.
+//
+// An implementation's tests just call Run with its constructor:
+//
+//	func TestConformance(t *testing.T) {
+//		bitmaptest.Run(t, ewah.New, bitmaptest.Options{Ascending: true})
+//	}
+package bitmaptest
+
+import (
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"math/rand"
+	"testing"
+)
+
+type Options struct {
+	// Ascending is true if the implementation only supports setting bits in ascending order, like
+	// EWAH. Otherwise bits are set in random order.
+	Ascending bool
+
+	// Seed seeds the random generator. Each round uses Seed+round, so a failing round can be
+	// reproduced on its own.
+	Seed int64
+
+	// Rounds is the number of random bitmaps to test. It defaults to 100.
+	Rounds int
+}
+
+// Run tests the implementation created by factory against the reference model.
+func Run(t *testing.T, factory func() bitmap.Bitmap, opts Options) {
+	if opts.Rounds == 0 {
+		opts.Rounds = 100
+	}
+
+	tests := []struct {
+		name string
+		f    func(*testing.T, *rand.Rand, func() bitmap.Bitmap, Options)
+	}{
+		{"SetGet", testSetGet},
+		{"Reset", testReset},
+		{"Clone", testClone},
+		{"Copy", testCopy},
+		{"Equal", testEqual},
+		{"And", testBinaryOp("And", func(a, b bool) bool { return a && b })},
+		{"Or", testBinaryOp("Or", func(a, b bool) bool { return a || b })},
+		{"AndNot", testBinaryOp("AndNot", func(a, b bool) bool { return a && !b })},
+		{"Xor", testBinaryOp("Xor", func(a, b bool) bool { return a != b })},
+		{"Not", testNot},
+		{"Predicates", testPredicates},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for round := 0; round < opts.Rounds; round++ {
+				seed := opts.Seed + int64(round)
+				r := rand.New(rand.NewSource(seed))
+
+				t.Run(fmt.Sprintf("seed=%d", seed), func(t *testing.T) {
+					test.f(t, r, factory, opts)
+				})
+
+				if t.Failed() {
+					return
+				}
+			}
+		})
+	}
+}
+
+// model is the reference implementation: one bool per bit, and the size is the length
+type model []bool
+
+func (this model) cardinality() int64 {
+	n := int64(0)
+	for _, v := range this {
+		if v {
+			n += 1
+		}
+	}
+
+	return n
+}
+
+func (this model) get(i int64) bool {
+	return i >= 0 && i < int64(len(this)) && this[i]
+}
+
+// Positions returns a random, sorted set of bit positions. The positions mix runs of consecutive
+// bits (often whole words, which compressed implementations store as running lengths), bits on
+// either side of word boundaries, and small and large gaps.
+func Positions(r *rand.Rand) []int64 {
+	var p []int64
+
+	bit := int64(r.Intn(130))
+
+	for n := r.Intn(40); n > 0; n-- {
+		switch r.Intn(5) {
+		case 0:
+			// A run of consecutive bits, sometimes whole aligned words
+			length := int64(r.Intn(300) + 1)
+			if r.Intn(2) == 0 {
+				bit = (bit + 63) / 64 * 64
+				length = int64(r.Intn(5)+1) * 64
+			}
+
+			for j := int64(0); j < length; j++ {
+				p = append(p, bit+j)
+			}
+
+			bit += length
+		case 1:
+			// Right before, on, or right after the next word boundary
+			bit = (bit/64+1)*64 + int64(r.Intn(3)) - 1
+			p = append(p, bit)
+			bit += 1
+		case 2:
+			// A large gap, which compressed implementations store as a running length of 0's
+			bit += int64(r.Intn(10000)) + 64
+			p = append(p, bit)
+			bit += 1
+		default:
+			bit += int64(r.Intn(40))
+			p = append(p, bit)
+			bit += 1
+		}
+	}
+
+	return p
+}
+
+// build creates a bitmap and its model from random positions. The positions are set in random order
+// unless the implementation requires ascending order.
+func build(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) (bitmap.Bitmap, model) {
+	p := Positions(r)
+
+	m := model{}
+	if len(p) > 0 {
+		m = make(model, p[len(p)-1]+1)
+	}
+
+	for _, v := range p {
+		m[v] = true
+	}
+
+	if !opts.Ascending {
+		r.Shuffle(len(p), func(i, j int) {
+			p[i], p[j] = p[j], p[i]
+		})
+	}
+
+	bm := factory()
+	for _, v := range p {
+		if bm.Set(v) == nil {
+			t.Fatalf("Set(%d) returned nil", v)
+		}
+	}
+
+	return bm, m
+}
+
+// check compares the bits of bm to the model, including the word after the end of the model
+func check(t *testing.T, what string, bm bitmap.Bitmap, m model) {
+	t.Helper()
+
+	for i := int64(0); i < int64(len(m))+64; i++ {
+		if v := bm.Get(i); v != m.get(i) {
+			t.Fatalf("%s: Get(%d) = %t, expected %t (size %d)", what, i, v, m.get(i), len(m))
+		}
+	}
+
+	if c := bm.Cardinality(); c != m.cardinality() {
+		t.Fatalf("%s: Cardinality() = %d, expected %d", what, c, m.cardinality())
+	}
+}
+
+func testSetGet(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) {
+	bm := factory()
+	if bm.Size() != 0 || bm.Cardinality() != 0 {
+		t.Fatalf("New bitmap has size %d and cardinality %d", bm.Size(), bm.Cardinality())
+	}
+
+	bm, m := build(t, r, factory, opts)
+
+	if bm.Size() != int64(len(m)) {
+		t.Fatalf("Size() = %d, expected %d", bm.Size(), len(m))
+	}
+
+	check(t, "Set", bm, m)
+
+	// Gets in random order, so implementations that remember the last position have to go back
+	for k := 0; k < 200 && len(m) > 0; k++ {
+		i := r.Int63n(int64(len(m)) + 128)
+		if v := bm.Get(i); v != m.get(i) {
+			t.Fatalf("Random Get(%d) = %t, expected %t", i, v, m.get(i))
+		}
+	}
+
+	if bm.Get(-1) {
+		t.Fatal("Get(-1) should be false")
+	}
+}
+
+func testReset(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) {
+	bm, _ := build(t, r, factory, opts)
+
+	bm.Reset()
+
+	if bm.Size() != 0 || bm.Cardinality() != 0 {
+		t.Fatalf("Reset bitmap has size %d and cardinality %d", bm.Size(), bm.Cardinality())
+	}
+
+	// The bitmap should be reusable after a reset
+	bm2, m := build(t, r, factory, opts)
+	for i := int64(0); i < int64(len(m)); i++ {
+		if m[i] {
+			bm.Set(i)
+		}
+	}
+
+	check(t, "Set after Reset", bm, m)
+
+	if !bm.Equal(bm2) {
+		t.Fatal("Reset and rebuilt bitmap is not equal to a new one")
+	}
+}
+
+func testClone(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) {
+	bm, m := build(t, r, factory, opts)
+
+	// Move any internal cursors before cloning
+	bm.Get(int64(len(m)) / 2)
+
+	c := bm.Clone()
+	check(t, "Clone", c, m)
+
+	if !c.Equal(bm) || !bm.Equal(c) {
+		t.Fatal("Clone is not equal to the original")
+	}
+
+	// Changing the clone should not change the original
+	next := int64(len(m)) + int64(r.Intn(200))
+	c.Set(next)
+
+	check(t, "Original after changing the clone", bm, m)
+
+	if !c.Get(next) {
+		t.Fatalf("Clone: Get(%d) = false after Set", next)
+	}
+}
+
+func testCopy(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) {
+	bm, m := build(t, r, factory, opts)
+	bm2, _ := build(t, r, factory, opts)
+
+	bm.Get(int64(len(m)) / 2)
+
+	if bm2.Copy(bm) == nil {
+		t.Fatal("Copy returned nil")
+	}
+
+	check(t, "Copy", bm2, m)
+
+	if !bm2.Equal(bm) {
+		t.Fatal("Copy is not equal to the original")
+	}
+
+	next := int64(len(m)) + int64(r.Intn(200))
+	bm2.Set(next)
+
+	check(t, "Original after changing the copy", bm, m)
+}
+
+func testEqual(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) {
+	bm, m := build(t, r, factory, opts)
+
+	bm2 := factory()
+	for i := int64(0); i < int64(len(m)); i++ {
+		if m[i] {
+			bm2.Set(i)
+		}
+	}
+
+	if !bm.Equal(bm2) || !bm2.Equal(bm) {
+		t.Fatal("Bitmaps with the same bits set in different orders are not equal")
+	}
+
+	// Bitmaps that differ in a single bit are not equal
+	if len(m) > 0 {
+		bm3 := factory()
+		first := true
+		for i := int64(0); i < int64(len(m)); i++ {
+			if m[i] {
+				if first {
+					first = false
+					continue
+				}
+				bm3.Set(i)
+			}
+		}
+
+		if bm.Equal(bm3) {
+			t.Fatal("Bitmaps that differ in the first bit are equal")
+		}
+	}
+}
+
+func testBinaryOp(name string, op func(a, b bool) bool) func(*testing.T, *rand.Rand, func() bitmap.Bitmap, Options) {
+	return func(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) {
+		bm, m := build(t, r, factory, opts)
+
+		n := r.Intn(3) + 1
+		others := make([]bitmap.Bitmap, n)
+		expected := append(model{}, m...)
+
+		for k := 0; k < n; k++ {
+			var mk model
+			others[k], mk = build(t, r, factory, opts)
+
+			size := len(expected)
+			if len(mk) > size {
+				size = len(mk)
+			}
+
+			next := make(model, size)
+			for i := range next {
+				next[i] = op(expected.get(int64(i)), mk.get(int64(i)))
+			}
+
+			expected = next
+		}
+
+		var ans bitmap.Bitmap
+		switch name {
+		case "And":
+			ans = bm.And(others...)
+		case "Or":
+			ans = bm.Or(others...)
+		case "AndNot":
+			ans = bm.AndNot(others...)
+		case "Xor":
+			ans = bm.Xor(others...)
+		}
+
+		if ans == nil {
+			t.Fatalf("%s returned nil", name)
+		}
+
+		check(t, fmt.Sprintf("%s of %d bitmaps", name, n+1), ans, expected)
+
+		// Implementations that count the bits of results without creating them should agree
+		if c, ok := bm.(bitmap.Cardinalities); ok && n == 1 {
+			var card int64
+			switch name {
+			case "And":
+				card = c.AndCardinality(others[0])
+			case "Or":
+				card = c.OrCardinality(others[0])
+			case "AndNot":
+				card = c.AndNotCardinality(others[0])
+			case "Xor":
+				card = c.XorCardinality(others[0])
+			}
+
+			if card != expected.cardinality() {
+				t.Fatalf("%sCardinality = %d, expected %d", name, card, expected.cardinality())
+			}
+		}
+
+		// The operands should not change
+		check(t, name+" operand", bm, m)
+
+		// Not flips every bit up to the size of the result, whatever that size is
+		testNotOf(t, name+" result", ans)
+	}
+}
+
+func (this model) isSubsetOf(other model) bool {
+	for i, v := range this {
+		if v && !other.get(int64(i)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (this model) intersects(other model) bool {
+	for i, v := range this {
+		if v && other.get(int64(i)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testPredicates(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) {
+	bm, m := build(t, r, factory, opts)
+	other, mo := build(t, r, factory, opts)
+
+	if v := bm.IsSubsetOf(other); v != m.isSubsetOf(mo) {
+		t.Fatalf("IsSubsetOf = %t, expected %t", v, !v)
+	}
+
+	if v := bm.IsSupersetOf(other); v != mo.isSubsetOf(m) {
+		t.Fatalf("IsSupersetOf = %t, expected %t", v, !v)
+	}
+
+	if v := bm.Intersects(other); v != m.intersects(mo) {
+		t.Fatalf("Intersects = %t, expected %t", v, !v)
+	}
+
+	if v := bm.IsDisjoint(other); v != !m.intersects(mo) {
+		t.Fatalf("IsDisjoint = %t, expected %t", v, !v)
+	}
+
+	// A bitmap is a subset and a superset of itself, and intersects itself unless it's empty
+	if !bm.IsSubsetOf(bm) || !bm.IsSupersetOf(bm) {
+		t.Fatal("Bitmap should be a subset and a superset of itself")
+	}
+
+	if bm.Intersects(bm) != (m.cardinality() > 0) {
+		t.Fatalf("Intersects itself = %t with cardinality %d", bm.Intersects(bm), m.cardinality())
+	}
+
+	// A nil bitmap is empty: only an empty bitmap is a subset of it, and no bitmap intersects it
+	empty := factory()
+	for _, o := range []bitmap.Bitmap{nil, empty} {
+		if bm.IsSubsetOf(o) != (m.cardinality() == 0) || !bm.IsSupersetOf(o) {
+			t.Fatalf("IsSubsetOf(%v) = %t and IsSupersetOf(%v) = %t with cardinality %d", o, bm.IsSubsetOf(o),
+				o, bm.IsSupersetOf(o), m.cardinality())
+		}
+
+		if bm.Intersects(o) || !bm.IsDisjoint(o) {
+			t.Fatalf("Bitmap should be disjoint from %v", o)
+		}
+
+		if !empty.IsSubsetOf(o) || !empty.IsSupersetOf(o) || empty.Intersects(o) || !empty.IsDisjoint(o) {
+			t.Fatalf("Empty bitmap should be a disjoint subset and superset of %v", o)
+		}
+	}
+
+	if !empty.IsSubsetOf(bm) || empty.IsSupersetOf(bm) != (m.cardinality() == 0) || empty.Intersects(bm) {
+		t.Fatal("Empty bitmap should be a subset of any bitmap")
+	}
+
+	// One more bit makes a strict superset
+	sup := bm.Clone()
+	sup.Set(int64(len(m)) + int64(r.Intn(200)))
+
+	if !bm.IsSubsetOf(sup) || !sup.IsSupersetOf(bm) || sup.IsSubsetOf(bm) || bm.IsSupersetOf(sup) {
+		t.Fatal("Bitmap with one more bit set should be a strict superset")
+	}
+}
+
+func testNot(t *testing.T, r *rand.Rand, factory func() bitmap.Bitmap, opts Options) {
+	bm, _ := build(t, r, factory, opts)
+
+	testNotOf(t, "Set", bm)
+}
+
+// testNotOf checks that Not flips every bit in [0, Size()) and keeps the size, and that a second
+// Not brings the bitmap back.
+func testNotOf(t *testing.T, what string, bm bitmap.Bitmap) {
+	t.Helper()
+
+	size := bm.Size()
+	m := make(model, size)
+	for i := range m {
+		m[i] = bm.Get(int64(i))
+	}
+
+	flipped := make(model, size)
+	for i := range flipped {
+		flipped[i] = !m[i]
+	}
+
+	if bm.Not() == nil {
+		t.Fatalf("%s: Not returned nil", what)
+	}
+
+	if bm.Size() != size {
+		t.Fatalf("%s: Not changed the size from %d to %d", what, size, bm.Size())
+	}
+
+	check(t, what+" after Not", bm, flipped)
+
+	bm.Not()
+	check(t, what+" after Not twice", bm, m)
+}
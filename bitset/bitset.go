@@ -16,13 +16,42 @@ type Bitset struct {
 }
 
 var _ bitmap.Bitmap = (*Bitset)(nil)
+var _ bitmap.Cardinalities = (*Bitset)(nil)
 
+// New returns an empty bitset of size 0, like an empty EWAH bitmap, so the two compare as equal.
 func New() bitmap.Bitmap {
 	return &Bitset{
-		b: bitset.New(4),
+		b: bitset.New(0),
 	}
 }
 
+// FromWords creates a bitset of sizeInBits bits from uncompressed 64-bit words, where bit i is
+// stored in words[i/64] at position i%64. The words are copied, and bits past sizeInBits are
+// dropped.
+func FromWords(words []uint64, sizeInBits int64) bitmap.Bitmap {
+	b := bitset.New(uint(sizeInBits))
+	set := b.Bytes()
+	copy(set, words)
+
+	if lastBits := sizeInBits % 64; lastBits != 0 {
+		set[len(set)-1] &= ^uint64(0) >> uint64(64-lastBits)
+	}
+
+	return &Bitset{
+		b: b,
+	}
+}
+
+// FromEwah creates a bitset from an EWAH bitmap by uncompressing it word by word. The ewah package
+// imports this one (for ewah.FromBitset), so e is declared as anything that can uncompress itself,
+// which *ewah.Ewah and *ewah.Frozen both do.
+func FromEwah(e interface {
+	Size() int64
+	Words() []uint64
+}) bitmap.Bitmap {
+	return FromWords(e.Words(), e.Size())
+}
+
 func (this *Bitset) Set(i int64) bitmap.Bitmap {
 	this.b.Set(uint(i))
 	return this
@@ -36,8 +65,10 @@ func (this *Bitset) Size() int64 {
 	return int64(this.b.Len())
 }
 
+// Reset clears every bit and sets the size back to 0. The cleared words are kept, and reused as the
+// bitset grows again.
 func (this *Bitset) Reset() {
-	this.b.ClearAll()
+	this.b = bitset.From(this.b.ClearAll().Bytes()[:0])
 }
 
 func (this *Bitset) Clone() bitmap.Bitmap {
@@ -47,21 +78,70 @@ func (this *Bitset) Clone() bitmap.Bitmap {
 }
 
 func (this *Bitset) Copy(other bitmap.Bitmap) bitmap.Bitmap {
-	this.b.Copy(other.(*Bitset).b)
+	o, ok := toBitset(other)
+	if !ok {
+		return nil
+	}
+
+	this.b = o.b.Clone()
 	return this
 }
 
 func (this *Bitset) Equal(other bitmap.Bitmap) bool {
-	return this.b.Equal(other.(*Bitset).b)
+	o, ok := toBitset(other)
+	if !ok {
+		return false
+	}
+
+	return this.b.Equal(o.b)
+}
+
+// SameBits returns true if other has the same bits set as this bitset, whatever its size or
+// implementation.
+func (this *Bitset) SameBits(other bitmap.Bitmap) bool {
+	if o, ok := toBitset(other); ok {
+		return this.b.SymmetricDifferenceCardinality(o.b) == 0
+	}
+
+	return bitmap.SameBits(this, other)
+}
+
+// Hash returns a hash of the positions of the bits set, see bitmap.Hash.
+func (this *Bitset) Hash() uint64 {
+	return bitmap.Hash(this)
 }
 
 func (this *Bitset) Cardinality() int64 {
 	return int64(this.b.Count())
 }
 
+// AndCardinality returns the number of bits set in both this bitset and other, without creating the
+// result of And.
+func (this *Bitset) AndCardinality(other bitmap.Bitmap) int64 {
+	return int64(this.b.IntersectionCardinality(asBitset(other).b))
+}
+
+// OrCardinality returns the number of bits set in either this bitset or other, without creating the
+// result of Or.
+func (this *Bitset) OrCardinality(other bitmap.Bitmap) int64 {
+	return int64(this.b.UnionCardinality(asBitset(other).b))
+}
+
+// AndNotCardinality returns the number of bits set in this bitset but not in other, without creating
+// the result of AndNot.
+func (this *Bitset) AndNotCardinality(other bitmap.Bitmap) int64 {
+	return int64(this.b.DifferenceCardinality(asBitset(other).b))
+}
+
+// XorCardinality returns the number of bits set in only one of this bitset and other, without
+// creating the result of Xor.
+func (this *Bitset) XorCardinality(other bitmap.Bitmap) int64 {
+	return int64(this.b.SymmetricDifferenceCardinality(asBitset(other).b))
+}
+
 func (this *Bitset) And(a ...bitmap.Bitmap) bitmap.Bitmap {
 	n := len(a)
-	bm, ok := a[0].(*Bitset)
+	bm, ok := toBitset(a[0])
 	if !ok {
 		return nil
 	}
@@ -70,7 +150,7 @@ func (this *Bitset) And(a ...bitmap.Bitmap) bitmap.Bitmap {
 	ans.b = this.b.Intersection(bm.b)
 
 	for i := 1; i < n; i++ {
-		bm, ok := a[i].(*Bitset)
+		bm, ok := toBitset(a[i])
 		if !ok {
 			return nil
 		}
@@ -83,7 +163,7 @@ func (this *Bitset) And(a ...bitmap.Bitmap) bitmap.Bitmap {
 
 func (this *Bitset) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
 	n := len(a)
-	bm, ok := a[0].(*Bitset)
+	bm, ok := toBitset(a[0])
 	if !ok {
 		return nil
 	}
@@ -92,7 +172,7 @@ func (this *Bitset) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
 	ans.b = this.b.Union(bm.b)
 
 	for i := 1; i < n; i++ {
-		bm, ok := a[i].(*Bitset)
+		bm, ok := toBitset(a[i])
 		if !ok {
 			return nil
 		}
@@ -105,7 +185,7 @@ func (this *Bitset) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
 
 func (this *Bitset) AndNot(a ...bitmap.Bitmap) bitmap.Bitmap {
 	n := len(a)
-	bm, ok := a[0].(*Bitset)
+	bm, ok := toBitset(a[0])
 	if !ok {
 		return nil
 	}
@@ -114,7 +194,7 @@ func (this *Bitset) AndNot(a ...bitmap.Bitmap) bitmap.Bitmap {
 	ans.b = this.b.Difference(bm.b)
 
 	for i := 1; i < n; i++ {
-		bm, ok := a[i].(*Bitset)
+		bm, ok := toBitset(a[i])
 		if !ok {
 			return nil
 		}
@@ -127,7 +207,7 @@ func (this *Bitset) AndNot(a ...bitmap.Bitmap) bitmap.Bitmap {
 
 func (this *Bitset) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
 	n := len(a)
-	bm, ok := a[0].(*Bitset)
+	bm, ok := toBitset(a[0])
 	if !ok {
 		return nil
 	}
@@ -136,7 +216,7 @@ func (this *Bitset) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
 	ans.b = this.b.SymmetricDifference(bm.b)
 
 	for i := 1; i < n; i++ {
-		bm, ok := a[i].(*Bitset)
+		bm, ok := toBitset(a[i])
 		if !ok {
 			return nil
 		}
@@ -147,7 +227,66 @@ func (this *Bitset) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
 	return ans
 }
 
+// Words returns a copy of the words of the bitset, where bit i is stored in word i/64 at position
+// i%64. Like ewah.Ewah.Words, the slice is not shared with the bitset.
+func (this *Bitset) Words() []uint64 {
+	words := make([]uint64, len(this.b.Bytes()))
+	copy(words, this.b.Bytes())
+
+	return words
+}
+
+// Stats reports how the bitset is stored. Bitsets are not compressed, so every word is a literal
+// word.
+func (this *Bitset) Stats() bitmap.Stats {
+	words := int64(len(this.b.Bytes()))
+
+	stats := bitmap.Stats{
+		SizeInBits:   this.Size(),
+		SizeInWords:  words,
+		SizeInBytes:  words * 8,
+		Cardinality:  this.Cardinality(),
+		LiteralWords: words,
+	}
+
+	if words > 0 {
+		stats.CompressionRatio = float64(stats.UncompressedWords()) / float64(words)
+	}
+
+	return stats
+}
+
+// MarshalBinary encodes the bitset in the format of github.com/willf/bitset.
+func (this *Bitset) MarshalBinary() ([]byte, error) {
+	return this.b.MarshalBinary()
+}
+
+func (this *Bitset) UnmarshalBinary(data []byte) error {
+	b := bitset.New(0)
+	if err := b.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	this.b = b
+	return nil
+}
+
 func (this *Bitset) Not() bitmap.Bitmap {
 	this.b = this.b.Complement()
 	return this
 }
+
+// toBitset returns the *Bitset behind a bitmap, unwrapping frozen bitsets and evaluating lazy
+// expressions. It returns false if the bitmap is not a Bitset.
+func toBitset(b bitmap.Bitmap) (*Bitset, bool) {
+	switch bm := b.(type) {
+	case *Bitset:
+		return bm, true
+	case *Frozen:
+		return bm.bm, true
+	case *bitmap.Expr:
+		return toBitset(bm.Eval())
+	}
+
+	return nil, false
+}
@@ -7,7 +7,9 @@
 package bitset
 
 import (
+	"fmt"
 	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitmaptest"
 	"math/rand"
 	"testing"
 )
@@ -358,6 +360,7 @@ func BenchmarkCardinality(b *testing.B) {
 		bm.Cardinality()
 	}
 }
+
 func BenchmarkAnd(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if bm.And(bm10) == nil {
@@ -398,47 +401,373 @@ func BenchmarkNot(b *testing.B) {
 	}
 }
 */
-// f is the function to call, like And, Or, Xor, AndNot
-// b1 is the number of bits for the first bitmap
-// b2 is the number of bits for the second bitmap
-// s1 is the sparsity of the first bitmap
-// s2 is the sparsity of the second bitmap
-func benchmarkDifferentCombinations(b *testing.B, op string, b1, b2 int, s1, s2 int) {
-	m1 := New().(*Bitset)
-	m2 := New().(*Bitset)
 
-	bit := int64(0)
-	rand.Seed(int64(c1))
-	for i := 0; i < b1; i++ {
-		bit += int64(rand.Intn(s1) + 1)
-		m1.Set(bit)
+func BenchmarkOps(b *testing.B) {
+	bitmaptest.Benchmark(b, New)
+}
+
+func TestNewEmpty(t *testing.T) {
+	// A new bitset used to be created with room for 4 bits, which made its size 4 instead of 0
+	bm2 := New()
+	if bm2.Size() != 0 || bm2.Cardinality() != 0 || bm2.Not().Cardinality() != 0 {
+		t.Fatalf("New bitset has size %d, should be empty", bm2.Size())
 	}
 
-	bit = 0
-	rand.Seed(int64(c2))
-	for i := 0; i < b2; i++ {
-		bit += int64(rand.Intn(s1) + 1)
-		m2.Set(bit)
+	if !bm2.Equal(FromWords(nil, 0)) || bm2.Set(3).Size() != 4 {
+		t.Fatal("New bitset should be the same as an empty one, and grow as bits are set")
 	}
+}
+
+func TestReset(t *testing.T) {
+	bm2 := New().Set(10).Set(100).Set(15000).(*Bitset)
+	words := cap(bm2.b.Bytes())
 
-	var f func(...bitmap.Bitmap) bitmap.Bitmap
-	switch op {
-	case "and":
-		f = m1.And
-	case "or":
-		f = m1.Or
-	case "andnot":
-		f = m1.AndNot
-	case "xor":
-		f = m1.Xor
-	default:
-		return
+	// ClearAll alone used to keep the size, so a reset bitset was not empty
+	bm2.Reset()
+	if bm2.Size() != 0 || bm2.Cardinality() != 0 || bm2.Get(100) || !bm2.Equal(New()) {
+		t.Fatalf("Reset bitset has size %d and cardinality %d", bm2.Size(), bm2.Cardinality())
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		if f(m2) == nil {
-			b.Fatal("Problem with %s benchmark at i =", op, i)
+	// The words are reused, and the bits set before the reset don't come back
+	bm2.Set(14000)
+	if cap(bm2.b.Bytes()) != words || bm2.Cardinality() != 1 || bm2.Get(100) || bm2.Size() != 14001 {
+		t.Fatal("Reset bitset should reuse its cleared words")
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	src := New().Set(10).Set(15000)
+	dst := New().Set(3).(*Bitset)
+
+	// willf/bitset's Copy copies its receiver into its argument, so Copy used to overwrite src with dst
+	if dst.Copy(src) == nil || !dst.Equal(src) || dst.Get(3) || src.Get(3) || src.Cardinality() != 2 {
+		t.Fatal("Copy should make the bitset the same as the other one")
+	}
+
+	dst.Set(20000)
+	if src.Get(20000) || src.Size() != 15001 {
+		t.Fatal("Copy should not share the words of the other bitset")
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	bm2 := New().(*Bitset)
+	bm2.Set(10)
+	bm2.Set(100)
+	bm2.Set(15000)
+
+	f := bm2.Freeze()
+
+	nums2 := []int64{15000, 100, 10}
+	for i := range nums2 {
+		if !f.Get(nums2[i]) {
+			t.Fatalf("Get(%d) failed, should be set\n", nums2[i])
+		}
+	}
+
+	if f.Cardinality() != 3 || !f.Equal(bm2) || !bm2.Equal(f) {
+		t.Fatal("Frozen bitset does not match the original")
+	}
+
+	bm3 := New().(*Bitset)
+	bm3.Set(100)
+
+	if c := bm3.And(f).Cardinality(); c != 1 {
+		t.Fatalf("And with frozen bitset: cardinality %d != 1", c)
+	}
+
+	if c := f.Clone().Set(20000).Cardinality(); c != 4 {
+		t.Fatalf("Clone of frozen bitset: cardinality %d != 4", c)
+	}
+
+	mutators := map[string]func(){
+		"Set":   func() { f.Set(20000) },
+		"Reset": func() { f.Reset() },
+		"Copy":  func() { f.Copy(bm3) },
+		"Not":   func() { f.Not() },
+	}
+
+	for name, fn := range mutators {
+		func() {
+			defer func() {
+				if r := recover(); r != bitmap.ErrFrozen {
+					t.Fatalf("%s on frozen bitset: expected panic with ErrFrozen, got %v", name, r)
+				}
+			}()
+			fn()
+		}()
+	}
+
+	// Changes to the original bitset must not show through the frozen copy
+	bm2.Set(20000)
+	bm2.Not()
+	if f.Cardinality() != 3 || !f.Get(15000) || f.Get(20000) {
+		t.Fatal("Frozen bitset changed with the original")
+	}
+
+	bm2.Copy(bm3)
+	bm2.Reset()
+	if f.Cardinality() != 3 || !f.Get(10) {
+		t.Fatal("Frozen bitset changed with the original")
+	}
+
+	// Writing to the words of a frozen bitset must not change it
+	g := New().Set(3).Set(40).(*Bitset).Freeze()
+	words := g.(*Frozen).Words()
+	words[0] = 1 << 5
+	if !g.Get(3) || !g.Get(40) || g.Get(5) || g.Cardinality() != 2 {
+		t.Fatal("Frozen bitset changed through its words")
+	}
+}
+
+func TestWords(t *testing.T) {
+	bm := New().Set(3).Set(40).Set(100).(*Bitset)
+
+	words := bm.Words()
+	if len(words) != 2 || words[0] != 1<<3|1<<40 || words[1] != 1<<36 {
+		t.Fatalf("Words() = %v", words)
+	}
+
+	// Words returns a copy, like EWAH bitmaps do
+	words[0] = ^uint64(0)
+	if bm.Get(0) || !bm.Get(3) || bm.Cardinality() != 3 {
+		t.Fatal("Changing the words changed the bitset")
+	}
+}
+
+func TestStats(t *testing.T) {
+	bm2 := New().(*Bitset)
+	bm2.Set(10)
+	bm2.Set(100)
+	bm2.Set(15000)
+
+	s := bm2.Stats()
+
+	if s.Cardinality != 3 || s.SizeInBits != bm2.Size() {
+		t.Fatalf("Stats do not match the bitset: %v", s)
+	}
+
+	if s.MarkerWords != 0 || s.LiteralWords != s.SizeInWords || s.SizeInBytes != s.SizeInWords*8 {
+		t.Fatalf("Every word of a bitset should be a literal word: %v", s)
+	}
+
+	if s.SizeInWords != s.UncompressedWords() || s.CompressionRatio != 1 {
+		t.Fatalf("Bitsets should not be compressed: %v", s)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	bitmaptest.Run(t, New, bitmaptest.Options{})
+}
+
+func TestSameBits(t *testing.T) {
+	bm := New()
+	bm2 := New()
+
+	for _, i := range []int64{5001, 10, 64, 1000, 65, 5000} {
+		bm.Set(i)
+		bm2.Set(i)
+	}
+
+	bm2.Set(6000)
+	bm3 := bm.Clone().Set(6000).AndNot(bm2.AndNot(bm))
+
+	if bm.Equal(bm3) {
+		t.Fatal("Bitsets of different sizes should not be equal")
+	}
+
+	if !bm.(*Bitset).SameBits(bm3) || !bm3.(*Bitset).SameBits(bm.(*Bitset).Freeze()) {
+		t.Fatal("Bitsets with the same bits set should have the same bits")
+	}
+
+	if bm.(*Bitset).SameBits(bm2) {
+		t.Fatal("Bitsets with different bits set should not have the same bits")
+	}
+
+	if bm.(*Bitset).Hash() != bm3.(*Bitset).Hash() {
+		t.Fatal("Bitsets with the same bits set should have the same hash")
+	}
+
+	if bm.(*Bitset).Hash() == bm2.(*Bitset).Hash() {
+		t.Fatal("Bitsets with different bits set should not have the same hash")
+	}
+}
+
+func TestShift(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		p := bitmaptest.Positions(r)
+
+		bm := New().(*Bitset)
+		for _, i := range p {
+			bm.Set(i)
+		}
+
+		for _, n := range []int64{0, 1, 63, 64, 65, 128, 200, int64(r.Intn(1000))} {
+			for _, left := range []bool{true, false} {
+				var ans bitmap.Bitmap
+				size, offset := bm.Size()+n, n
+				if left {
+					ans = bm.ShiftLeft(n)
+				} else {
+					ans = bm.ShiftRight(n)
+					size, offset = bm.Size()-n, -n
+				}
+
+				if size < 0 {
+					size = 0
+				}
+
+				if ans.Size() != size {
+					t.Fatalf("seed %d, shift %d left %t: Size() = %d, expected %d", seed, n, left, ans.Size(), size)
+				}
+
+				expected := New()
+				for _, i := range p {
+					if i+offset >= 0 {
+						expected.Set(i + offset)
+					}
+				}
+
+				if !ans.(*Bitset).SameBits(expected) || ans.Cardinality() != expected.Cardinality() {
+					t.Fatalf("seed %d, shift %d left %t: wrong bits", seed, n, left)
+				}
+			}
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	bm := New().Set(5).Set(70)
+	bm2 := New().Set(0).Set(64)
+
+	ans := bm.(*Bitset).Append(bm2)
+	if ans.Size() != 71+65 || ans.Cardinality() != 4 || !ans.Get(71) || !ans.Get(71+64) || !ans.Get(70) {
+		t.Fatal("Append put the bits in the wrong place")
+	}
+
+	c := bitmap.Concat(New().Set(1), New().Set(1), New().Set(1))
+	if c.Size() != 6 || c.Cardinality() != 3 || !c.Get(1) || !c.Get(3) || !c.Get(5) {
+		t.Fatal("Concat put the bits in the wrong place")
+	}
+}
+
+func TestSlice(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		p := bitmaptest.Positions(r)
+
+		bm := New().(*Bitset)
+		for _, i := range p {
+			bm.Set(i)
+		}
+
+		for k := 0; k < 10; k++ {
+			start := r.Int63n(bm.Size() + 100)
+			if k%2 == 0 {
+				start = start / 64 * 64
+			}
+
+			end := start + r.Int63n(bm.Size()+100)
+
+			slice, rng := bm.Slice(start, end).(*Bitset), bm.Range(start, end).(*Bitset)
+
+			if end > bm.Size() {
+				end = bm.Size()
+			}
+
+			s, e := New(), New()
+			for _, i := range p {
+				if i >= start && i < end {
+					s.Set(i - start)
+					e.Set(i)
+				}
+			}
+
+			if (start < end && slice.Size() != end-start) || rng.Size() != end {
+				t.Fatalf("seed %d, [%d, %d): sizes %d and %d", seed, start, end, slice.Size(), rng.Size())
+			}
+
+			if !slice.SameBits(s) || slice.Cardinality() != s.Cardinality() {
+				t.Fatalf("seed %d: Slice(%d, %d) has the wrong bits", seed, start, end)
+			}
+
+			if !rng.SameBits(e) || rng.Cardinality() != e.Cardinality() {
+				t.Fatalf("seed %d: Range(%d, %d) has the wrong bits", seed, start, end)
+			}
 		}
 	}
 }
+
+func TestRangeEmpty(t *testing.T) {
+	bm := New().Set(10).Set(100).Set(1000).(*Bitset)
+
+	// Empty ranges keep the size of the range, clipped to the size of the bitmap
+	for _, c := range [][3]int64{{5, 5, 5}, {100, 100, 100}, {640, 640, 640}, {500, 100, 100}, {2000, 1500, 1001},
+		{5000, 5000, 1001}, {-10, -10, 0}, {0, 0, 0}} {
+		rng := bm.Range(c[0], c[1])
+		if rng.Size() != c[2] || rng.Cardinality() != 0 {
+			t.Fatalf("Range(%d, %d): size %d, cardinality %d, expected size %d", c[0], c[1], rng.Size(),
+				rng.Cardinality(), c[2])
+		}
+	}
+}
+
+func TestAllRuns(t *testing.T) {
+	bm := New().Set(1).Set(63).Set(64).Set(65).Set(200)
+	for i := int64(256); i < 512; i++ {
+		bm.Set(i)
+	}
+
+	var all []int64
+	for i := range bm.(*Bitset).All() {
+		all = append(all, i)
+	}
+
+	if len(all) != 5+256 || all[3] != 65 || all[5] != 256 || all[len(all)-1] != 511 {
+		t.Fatalf("All() returned %v", all)
+	}
+
+	var runs []string
+	for start, n := range bm.(*Bitset).Freeze().(*Frozen).Runs() {
+		runs = append(runs, fmt.Sprintf("%d+%d", start, n))
+		if start == 200 {
+			break
+		}
+	}
+
+	if fmt.Sprint(runs) != "[1+1 63+3 200+1]" {
+		t.Fatalf("Runs() = %v", runs)
+	}
+
+	runs = nil
+	for start, n := range bm.(*Bitset).Runs() {
+		runs = append(runs, fmt.Sprintf("%d+%d", start, n))
+	}
+
+	if fmt.Sprint(runs) != "[1+1 63+3 200+1 256+256]" {
+		t.Fatalf("Runs() = %v", runs)
+	}
+
+	var back []int64
+	for i := range bm.(*Bitset).Backward() {
+		back = append(back, i)
+	}
+
+	for k, i := range back {
+		if i != all[len(all)-1-k] || len(back) != len(all) {
+			t.Fatalf("Backward() returned %d at %d", i, k)
+		}
+	}
+
+	back = nil
+	for i := range bm.(*Bitset).Freeze().(*Frozen).Backward() {
+		if back = append(back, i); i == 65 {
+			break
+		}
+	}
+
+	if len(back) != 256+2 || fmt.Sprint(back[256:]) != "[200 65]" {
+		t.Fatalf("Backward() = %v", back)
+	}
+}
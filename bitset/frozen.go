@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitset
+
+import (
+	"github.com/reducedb/bitmap"
+	"iter"
+)
+
+// Frozen is an immutable copy of a Bitset. Set, Reset, Copy and Not panic with bitmap.ErrFrozen.
+type Frozen struct {
+	bm *Bitset
+}
+
+var _ bitmap.Bitmap = (*Frozen)(nil)
+var _ bitmap.Cardinalities = (*Frozen)(nil)
+
+// Freeze returns an immutable copy of the bitset. Later changes to the bitset do not affect the
+// frozen copy.
+func (this *Bitset) Freeze() bitmap.Bitmap {
+	return &Frozen{bm: this.Clone().(*Bitset)}
+}
+
+func (this *Frozen) Freeze() bitmap.Bitmap {
+	return this
+}
+
+func (this *Frozen) Set(i int64) bitmap.Bitmap {
+	panic(bitmap.ErrFrozen)
+}
+
+func (this *Frozen) Get(i int64) bool {
+	return this.bm.Get(i)
+}
+
+func (this *Frozen) Size() int64 {
+	return this.bm.Size()
+}
+
+func (this *Frozen) Stats() bitmap.Stats {
+	return this.bm.Stats()
+}
+
+func (this *Frozen) Reset() {
+	panic(bitmap.ErrFrozen)
+}
+
+// Clone returns a mutable copy of the frozen bitset.
+func (this *Frozen) Clone() bitmap.Bitmap {
+	return this.bm.Clone()
+}
+
+func (this *Frozen) Copy(other bitmap.Bitmap) bitmap.Bitmap {
+	panic(bitmap.ErrFrozen)
+}
+
+func (this *Frozen) Equal(other bitmap.Bitmap) bool {
+	return this.bm.Equal(other)
+}
+
+func (this *Frozen) SameBits(other bitmap.Bitmap) bool {
+	return this.bm.SameBits(other)
+}
+
+func (this *Frozen) Hash() uint64 {
+	return this.bm.Hash()
+}
+
+// Words returns a copy of the words of the bitset, so the frozen bitset can't be changed through it.
+func (this *Frozen) Words() []uint64 {
+	return this.bm.Words()
+}
+
+func (this *Frozen) MarshalBinary() ([]byte, error) {
+	return this.bm.MarshalBinary()
+}
+
+func (this *Frozen) All() iter.Seq[int64] {
+	return this.bm.All()
+}
+
+func (this *Frozen) Backward() iter.Seq[int64] {
+	return this.bm.Backward()
+}
+
+func (this *Frozen) Runs() iter.Seq2[int64, int64] {
+	return this.bm.Runs()
+}
+
+func (this *Frozen) Cardinality() int64 {
+	return this.bm.Cardinality()
+}
+
+func (this *Frozen) IsSubsetOf(other bitmap.Bitmap) bool {
+	return this.bm.IsSubsetOf(other)
+}
+
+func (this *Frozen) IsSupersetOf(other bitmap.Bitmap) bool {
+	return this.bm.IsSupersetOf(other)
+}
+
+func (this *Frozen) Intersects(other bitmap.Bitmap) bool {
+	return this.bm.Intersects(other)
+}
+
+func (this *Frozen) IsDisjoint(other bitmap.Bitmap) bool {
+	return this.bm.IsDisjoint(other)
+}
+
+func (this *Frozen) AndCardinality(other bitmap.Bitmap) int64 {
+	return this.bm.AndCardinality(other)
+}
+
+func (this *Frozen) OrCardinality(other bitmap.Bitmap) int64 {
+	return this.bm.OrCardinality(other)
+}
+
+func (this *Frozen) AndNotCardinality(other bitmap.Bitmap) int64 {
+	return this.bm.AndNotCardinality(other)
+}
+
+func (this *Frozen) XorCardinality(other bitmap.Bitmap) int64 {
+	return this.bm.XorCardinality(other)
+}
+
+func (this *Frozen) And(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.bm.And(a...)
+}
+
+func (this *Frozen) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.bm.Or(a...)
+}
+
+func (this *Frozen) AndNot(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.bm.AndNot(a...)
+}
+
+func (this *Frozen) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.bm.Xor(a...)
+}
+
+func (this *Frozen) ShiftLeft(n int64) bitmap.Bitmap {
+	return this.bm.ShiftLeft(n)
+}
+
+func (this *Frozen) ShiftRight(n int64) bitmap.Bitmap {
+	return this.bm.ShiftRight(n)
+}
+
+func (this *Frozen) Slice(start, end int64) bitmap.Bitmap {
+	return this.bm.Slice(start, end)
+}
+
+func (this *Frozen) Range(start, end int64) bitmap.Bitmap {
+	return this.bm.Range(start, end)
+}
+
+func (this *Frozen) Not() bitmap.Bitmap {
+	panic(bitmap.ErrFrozen)
+}
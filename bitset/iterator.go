@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitset
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// All returns an iterator over the positions of the bits set, in ascending order. The bitset must
+// not be modified while it's being iterated over.
+func (this *Bitset) All() iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		for i, w := range this.b.Bytes() {
+			for ; w != 0; w &= w - 1 {
+				if !yield(int64(i)*64 + int64(bits.TrailingZeros64(w))) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the positions of the bits set, in descending order. The bitset
+// must not be modified while it's being iterated over.
+func (this *Bitset) Backward() iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		words := this.b.Bytes()
+
+		for i := len(words) - 1; i >= 0; i-- {
+			for w := words[i]; w != 0; {
+				b := 63 - bits.LeadingZeros64(w)
+				w &^= uint64(1) << uint(b)
+
+				if !yield(int64(i)*64 + int64(b)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Runs returns an iterator over the runs of consecutive bits set, as the position of the first bit
+// and the number of bits, in ascending order. Runs that span several words come out as one.
+func (this *Bitset) Runs() iter.Seq2[int64, int64] {
+	return func(yield func(int64, int64) bool) {
+		start, n := int64(0), int64(0)
+
+		for i, w := range this.b.Bytes() {
+			for w != 0 {
+				tz := bits.TrailingZeros64(w)
+				ones := bits.TrailingZeros64(^(w >> uint(tz)))
+				s := int64(i)*64 + int64(tz)
+
+				// Extend the current run if this one starts right after it
+				if n > 0 && s == start+n {
+					n += int64(ones)
+				} else {
+					if n > 0 && !yield(start, n) {
+						return
+					}
+					start, n = s, int64(ones)
+				}
+
+				w &^= (uint64(1)<<uint(ones) - 1) << uint(tz)
+			}
+		}
+
+		if n > 0 {
+			yield(start, n)
+		}
+	}
+}
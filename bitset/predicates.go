@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitset
+
+import (
+	"github.com/reducedb/bitmap"
+)
+
+// IsSubsetOf returns true if every bit set in this bitset is also set in other. A nil bitmap is
+// empty, so an empty bitset is a subset of nil.
+func (this *Bitset) IsSubsetOf(other bitmap.Bitmap) bool {
+	return !this.anyWords(asBitset(other), func(x, y uint64) bool {
+		return x&^y != 0
+	})
+}
+
+// IsSupersetOf returns true if every bit set in other is also set in this bitset.
+func (this *Bitset) IsSupersetOf(other bitmap.Bitmap) bool {
+	return !this.anyWords(asBitset(other), func(x, y uint64) bool {
+		return y&^x != 0
+	})
+}
+
+// Intersects returns true if at least one bit is set in both this bitset and other.
+func (this *Bitset) Intersects(other bitmap.Bitmap) bool {
+	return this.anyWords(asBitset(other), func(x, y uint64) bool {
+		return x&y != 0
+	})
+}
+
+// IsDisjoint returns true if no bit is set in both this bitset and other.
+func (this *Bitset) IsDisjoint(other bitmap.Bitmap) bool {
+	return !this.Intersects(other)
+}
+
+// anyWords returns true as soon as f returns true for a pair of words at the same position in this
+// bitset and a. Words past the end of the shorter bitset are 0's.
+func (this *Bitset) anyWords(a *Bitset, f func(x, y uint64) bool) bool {
+	w1, w2 := this.b.Bytes(), a.b.Bytes()
+
+	for k := 0; k < len(w1) || k < len(w2); k++ {
+		var x, y uint64
+
+		if k < len(w1) {
+			x = w1[k]
+		}
+
+		if k < len(w2) {
+			y = w2[k]
+		}
+
+		if f(x, y) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// asBitset returns b as a bitset, converting it if it's not one. A nil bitmap is empty.
+func asBitset(b bitmap.Bitmap) *Bitset {
+	if b == nil {
+		return New().(*Bitset)
+	}
+
+	if bs, ok := toBitset(b); ok {
+		return bs
+	}
+
+	if w, ok := b.(interface {
+		Words() []uint64
+	}); ok {
+		return FromWords(w.Words(), b.Size()).(*Bitset)
+	}
+
+	return bitmap.Convert(b, New).(*Bitset)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitset
+
+import (
+	"github.com/reducedb/bitmap"
+)
+
+// ShiftLeft returns a new bitset with every bit moved n positions up, and a size n bits larger. A
+// negative n shifts right.
+func (this *Bitset) ShiftLeft(n int64) bitmap.Bitmap {
+	if n < 0 {
+		return this.ShiftRight(-n)
+	}
+
+	size := this.Size() + n
+	words := make([]uint64, (size+63)/64)
+	q, r := int(n/64), uint64(n%64)
+
+	for k, w := range this.b.Bytes() {
+		if k+q < len(words) {
+			words[k+q] |= w << r
+		}
+
+		if r != 0 && k+q+1 < len(words) {
+			words[k+q+1] |= w >> (64 - r)
+		}
+	}
+
+	return FromWords(words, size)
+}
+
+// ShiftRight returns a new bitset with every bit moved n positions down, and a size n bits smaller.
+// Bits that would move below 0 are dropped. A negative n shifts left.
+func (this *Bitset) ShiftRight(n int64) bitmap.Bitmap {
+	if n < 0 {
+		return this.ShiftLeft(-n)
+	}
+
+	return this.Slice(n, this.Size())
+}
+
+// Slice returns a new bitset with the bits in [start, end), moved down by start so bit start becomes
+// bit 0. The size of the new bitset is end-start. The range is clipped to the size of this bitset.
+func (this *Bitset) Slice(start, end int64) bitmap.Bitmap {
+	if start < 0 {
+		start = 0
+	}
+
+	if end > this.Size() {
+		end = this.Size()
+	}
+
+	if start >= end {
+		return New()
+	}
+
+	src := this.b.Bytes()
+	words := make([]uint64, (end-start+63)/64)
+	q, r := int(start/64), uint64(start%64)
+
+	for k := range words {
+		if k+q < len(src) {
+			words[k] = src[k+q] >> r
+		}
+
+		if r != 0 && k+q+1 < len(src) {
+			words[k] |= src[k+q+1] << (64 - r)
+		}
+	}
+
+	return FromWords(words, end-start)
+}
+
+// Range returns a new bitset with only the bits in [start, end), which stay where they are. The size
+// of the new bitset is end, even if the range is empty. The range is clipped to the size of this
+// bitset.
+func (this *Bitset) Range(start, end int64) bitmap.Bitmap {
+	if start < 0 {
+		start = 0
+	}
+
+	if end > this.Size() {
+		end = this.Size()
+	}
+
+	if start >= end {
+		if end < 0 {
+			end = 0
+		}
+
+		return FromWords(nil, end)
+	}
+
+	words := make([]uint64, (end+63)/64)
+	q := start / 64
+
+	copy(words[q:], this.b.Bytes()[q:])
+	words[q] &= ^uint64(0) << uint64(start%64)
+
+	return FromWords(words, end)
+}
+
+// Append adds the bits of other after the end of this bitset, so bit i of other becomes bit Size()+i,
+// and the size grows by other.Size().
+func (this *Bitset) Append(other bitmap.Bitmap) bitmap.Bitmap {
+	o := asBitset(other).ShiftLeft(this.Size()).(*Bitset)
+	o.b.InPlaceUnion(this.b)
+	this.b = o.b
+
+	return this
+}
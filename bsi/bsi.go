@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Package bsi implements bit-sliced indexes. A bit-sliced index stores an unsigned integer value for
+// each row as one bitmap per bit of the values, plus a bitmap of the rows that have a value. Range
+// queries, sums and top-k queries are then answered with bitwise operations on a few bitmaps instead
+// of scanning the values.
+//
+// Any bitmap.Bitmap implementation can be used for the bitmaps. With EWAH bitmaps, rows have to be
+// added in ascending order:
+//
+//	idx := bsi.New(ewah.New)
+//	idx.SetValue(0, 42)
+//	idx.SetValue(3, 7)
+//	rows := idx.Between(5, 50)
+//
+// Reference: O'Neil and Quass, Improved Query Performance with Variant Indexes, SIGMOD 1997.
+package bsi
+
+import (
+	"errors"
+	"fmt"
+	"github.com/reducedb/bitmap"
+)
+
+type BSI struct {
+	factory func() bitmap.Bitmap
+
+	// exists has a bit set for every row that has a value
+	exists bitmap.Bitmap
+
+	// slices[i] has a bit set for every row whose value has bit i set
+	slices []bitmap.Bitmap
+}
+
+func New(factory func() bitmap.Bitmap) *BSI {
+	return &BSI{
+		factory: factory,
+		exists:  factory(),
+	}
+}
+
+// SetValue sets the value of a row. A row can only be set once, and implementations like EWAH also
+// require rows to be set in ascending order. If a bitmap refuses the row, the bits already set for it
+// are cleared again, so the index is left as it was.
+func (this *BSI) SetValue(row int64, v uint64) error {
+	if this.exists.Get(row) {
+		return fmt.Errorf("bsi/SetValue: row %d already has a value", row)
+	}
+
+	if this.exists.Set(row) == nil {
+		return fmt.Errorf("bsi/SetValue: cannot set row %d", row)
+	}
+
+	for i := 0; v>>uint(i) != 0; i++ {
+		for len(this.slices) <= i {
+			this.slices = append(this.slices, this.factory())
+		}
+
+		if v&(1<<uint(i)) != 0 && this.slices[i].Set(row) == nil {
+			this.unset(row, v&(1<<uint(i)-1))
+			return errors.New("bsi/SetValue: bit slices are out of sync with the existence bitmap")
+		}
+	}
+
+	return nil
+}
+
+// unset clears row in the existence bitmap and in the slices of the bits set in v. Bitmaps can't
+// clear bits, so the row is removed with AndNot.
+func (this *BSI) unset(row int64, v uint64) {
+	r := this.factory().Set(row)
+
+	this.exists = this.exists.AndNot(r)
+	for i := range this.slices {
+		if v&(1<<uint(i)) != 0 {
+			this.slices[i] = this.slices[i].AndNot(r)
+		}
+	}
+}
+
+// GetValue returns the value of a row, and false if the row has no value.
+func (this *BSI) GetValue(row int64) (uint64, bool) {
+	if !this.exists.Get(row) {
+		return 0, false
+	}
+
+	v := uint64(0)
+	for i, s := range this.slices {
+		if s.Get(row) {
+			v |= 1 << uint(i)
+		}
+	}
+
+	return v, true
+}
+
+// Exists returns the bitmap of rows that have a value. It's shared with the index.
+func (this *BSI) Exists() bitmap.Bitmap {
+	return this.exists
+}
+
+// BitDepth returns the number of bit slices, which is the number of bits of the largest value.
+func (this *BSI) BitDepth() int {
+	return len(this.slices)
+}
+
+// Eq returns the rows whose value is v.
+func (this *BSI) Eq(v uint64) bitmap.Bitmap {
+	_, eq, _ := this.compare(v)
+	return eq
+}
+
+// Lt returns the rows whose value is less than v.
+func (this *BSI) Lt(v uint64) bitmap.Bitmap {
+	lt, _, _ := this.compare(v)
+	return lt
+}
+
+// Le returns the rows whose value is less than or equal to v.
+func (this *BSI) Le(v uint64) bitmap.Bitmap {
+	lt, eq, _ := this.compare(v)
+	return lt.Or(eq)
+}
+
+// Gt returns the rows whose value is greater than v.
+func (this *BSI) Gt(v uint64) bitmap.Bitmap {
+	_, _, gt := this.compare(v)
+	return gt
+}
+
+// Ge returns the rows whose value is greater than or equal to v.
+func (this *BSI) Ge(v uint64) bitmap.Bitmap {
+	_, eq, gt := this.compare(v)
+	return gt.Or(eq)
+}
+
+// Between returns the rows whose value is between lo and hi, inclusive.
+func (this *BSI) Between(lo, hi uint64) bitmap.Bitmap {
+	if lo > hi {
+		return this.factory()
+	}
+
+	return this.Ge(lo).And(this.Le(hi))
+}
+
+// compare returns the rows whose value is less than, equal to, and greater than v. It goes through
+// the bit slices from the most significant bit down, narrowing down the rows that are still equal to
+// v so far.
+func (this *BSI) compare(v uint64) (lt, eq, gt bitmap.Bitmap) {
+	lt, eq, gt = this.factory(), this.exists.Clone(), this.factory()
+
+	// If v has more bits than the largest value, every value is smaller
+	if v>>uint(len(this.slices)) != 0 {
+		return this.exists.Clone(), this.factory(), this.factory()
+	}
+
+	for i := len(this.slices) - 1; i >= 0; i-- {
+		if v&(1<<uint(i)) != 0 {
+			lt = lt.Or(eq.AndNot(this.slices[i]))
+			eq = eq.And(this.slices[i])
+		} else {
+			gt = gt.Or(eq.And(this.slices[i]))
+			eq = eq.AndNot(this.slices[i])
+		}
+	}
+
+	return lt, eq, gt
+}
+
+// Sum returns the sum of the values of the rows in filter, and the number of rows summed. A nil
+// filter sums all the rows. Only the cardinalities of the bit slices are needed.
+func (this *BSI) Sum(filter bitmap.Bitmap) (uint64, int64) {
+	f := this.exists
+	if filter != nil {
+		f = f.And(filter)
+	}
+
+	sum := uint64(0)
+	for i, s := range this.slices {
+		sum += uint64(bitmap.AndCardinality(s, f)) << uint(i)
+	}
+
+	return sum, f.Cardinality()
+}
+
+// TopK returns the k rows in filter with the largest values. A nil filter considers all the rows. Ties
+// with the k-th largest value go to the lowest rows.
+func (this *BSI) TopK(k int64, filter bitmap.Bitmap) bitmap.Bitmap {
+	// g has the rows that are in the top k for sure, and e the rows that might still be
+	g, e := this.factory(), this.exists.Clone()
+	if filter != nil {
+		e = e.And(filter)
+	}
+
+	if k <= 0 {
+		return g
+	}
+
+	for i := len(this.slices) - 1; i >= 0; i-- {
+		x := g.Or(e.And(this.slices[i]))
+		n := x.Cardinality()
+
+		if n > k {
+			e = e.And(this.slices[i])
+		} else if n < k {
+			g = x
+			e = e.AndNot(this.slices[i])
+		} else {
+			return x
+		}
+	}
+
+	// All the rows left in e have the same value, so only as many as are missing are taken, in
+	// ascending order
+	missing := k - g.Cardinality()
+	if e.Cardinality() <= missing {
+		return g.Or(e)
+	}
+
+	ties := this.factory()
+	for i := range bitmap.All(e) {
+		if missing == 0 {
+			break
+		}
+
+		ties.Set(i)
+		missing -= 1
+	}
+
+	return g.Or(ties)
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bsi
+
+import (
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/ewah"
+	"math/rand"
+	"testing"
+)
+
+var factories = map[string]func() bitmap.Bitmap{
+	"ewah":   ewah.New,
+	"bitset": bitset.New,
+}
+
+// newModel returns an index with a random value for about half of n rows, and the values in a map
+func newModel(t *testing.T, factory func() bitmap.Bitmap, n int64) (*BSI, map[int64]uint64) {
+	r := rand.New(rand.NewSource(1))
+	idx := New(factory)
+	values := make(map[int64]uint64)
+
+	for row := int64(0); row < n; row++ {
+		if r.Intn(2) == 0 {
+			continue
+		}
+
+		v := uint64(r.Intn(100))
+		if err := idx.SetValue(row, v); err != nil {
+			t.Fatal(err)
+		}
+		values[row] = v
+	}
+
+	return idx, values
+}
+
+func checkRows(t *testing.T, name string, bm bitmap.Bitmap, values map[int64]uint64, f func(uint64) bool) {
+	n := int64(0)
+	for row, v := range values {
+		if f(v) {
+			n += 1
+			if !bm.Get(row) {
+				t.Fatalf("%s: row %d with value %d is missing", name, row, v)
+			}
+		}
+	}
+
+	if bm.Cardinality() != n {
+		t.Fatalf("%s: cardinality %d != %d", name, bm.Cardinality(), n)
+	}
+}
+
+func TestGetSetValue(t *testing.T) {
+	for name, factory := range factories {
+		idx, values := newModel(t, factory, 1000)
+
+		for row := int64(0); row < 1000; row++ {
+			v, ok := idx.GetValue(row)
+			if w, found := values[row]; ok != found || v != w {
+				t.Fatalf("%s: GetValue(%d) = %d, %v, should be %d, %v", name, row, v, ok, w, found)
+			}
+		}
+
+		if idx.BitDepth() != 7 {
+			t.Fatalf("%s: BitDepth %d != 7", name, idx.BitDepth())
+		}
+
+		if idx.SetValue(10, 1) == nil {
+			t.Fatalf("%s: SetValue should fail on a row that has a value", name)
+		}
+	}
+}
+
+// refusing is a bitmap that refuses to set one row
+type refusing struct {
+	bitmap.Bitmap
+	row int64
+}
+
+func (this refusing) Set(i int64) bitmap.Bitmap {
+	if i == this.row {
+		return nil
+	}
+
+	return this.Bitmap.Set(i)
+}
+
+func TestSetValueFails(t *testing.T) {
+	for name, factory := range factories {
+		// The existence bitmap and the first slice accept every row, but the second slice refuses row 5
+		n := 0
+		idx := New(func() bitmap.Bitmap {
+			n++
+			if n == 3 {
+				return refusing{factory(), 5}
+			}
+			return factory()
+		})
+
+		idx.SetValue(1, 3)
+		if idx.SetValue(5, 3) == nil {
+			t.Fatalf("%s: SetValue should fail when a slice refuses the row", name)
+		}
+
+		if _, ok := idx.GetValue(5); ok || idx.Exists().Get(5) {
+			t.Fatalf("%s: failed SetValue left row 5 in the index", name)
+		}
+
+		if v, ok := idx.GetValue(1); !ok || v != 3 || idx.Exists().Cardinality() != 1 {
+			t.Fatalf("%s: failed SetValue changed row 1 to %d, %v", name, v, ok)
+		}
+
+		if err := idx.SetValue(6, 1); err != nil {
+			t.Fatalf("%s: SetValue after a failed one: %v", name, err)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	for name, factory := range factories {
+		idx, values := newModel(t, factory, 1000)
+
+		for _, c := range []uint64{0, 1, 37, 64, 99, 100, 1000} {
+			checkRows(t, name+"/Eq", idx.Eq(c), values, func(v uint64) bool { return v == c })
+			checkRows(t, name+"/Lt", idx.Lt(c), values, func(v uint64) bool { return v < c })
+			checkRows(t, name+"/Le", idx.Le(c), values, func(v uint64) bool { return v <= c })
+			checkRows(t, name+"/Gt", idx.Gt(c), values, func(v uint64) bool { return v > c })
+			checkRows(t, name+"/Ge", idx.Ge(c), values, func(v uint64) bool { return v >= c })
+			checkRows(t, name+"/Between", idx.Between(c/2, c), values, func(v uint64) bool { return v >= c/2 && v <= c })
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	for name, factory := range factories {
+		idx, values := newModel(t, factory, 1000)
+
+		filter := factory()
+		for row := int64(0); row < 1000; row += 3 {
+			filter.Set(row)
+		}
+
+		total, filtered, n := uint64(0), uint64(0), int64(0)
+		for row, v := range values {
+			total += v
+			if row%3 == 0 {
+				filtered += v
+				n += 1
+			}
+		}
+
+		if sum, count := idx.Sum(nil); sum != total || count != int64(len(values)) {
+			t.Fatalf("%s: Sum(nil) = %d, %d, should be %d, %d", name, sum, count, total, len(values))
+		}
+
+		if sum, count := idx.Sum(filter); sum != filtered || count != n {
+			t.Fatalf("%s: Sum(filter) = %d, %d, should be %d, %d", name, sum, count, filtered, n)
+		}
+	}
+}
+
+func TestTopK(t *testing.T) {
+	for name, factory := range factories {
+		idx, values := newModel(t, factory, 1000)
+
+		for _, k := range []int64{0, 1, 10, 100, int64(len(values)), 2000} {
+			top := idx.TopK(k, nil)
+
+			want := k
+			if want > int64(len(values)) {
+				want = int64(len(values))
+			}
+
+			if top.Cardinality() != want {
+				t.Fatalf("%s: TopK(%d) has %d rows", name, k, top.Cardinality())
+			}
+
+			// Every row in the result has a value at least as large as every row left out
+			min, max := uint64(100), uint64(0)
+			for row, v := range values {
+				if top.Get(row) && v < min {
+					min = v
+				} else if !top.Get(row) && v > max {
+					max = v
+				}
+			}
+
+			if k > 0 && k < int64(len(values)) && min < max {
+				t.Fatalf("%s: TopK(%d) has value %d but leaves out %d", name, k, min, max)
+			}
+		}
+	}
+}
+
+func TestTopKTies(t *testing.T) {
+	for name, factory := range factories {
+		idx := New(factory)
+		for _, row := range []int64{3, 10, 64, 100, 1000, 5000} {
+			idx.SetValue(row, 7)
+		}
+		idx.SetValue(6000, 9)
+
+		// Ties go to the lowest rows
+		top := idx.TopK(3, nil)
+		if top.Cardinality() != 3 || !top.Get(6000) || !top.Get(3) || !top.Get(10) {
+			t.Fatalf("%s: TopK(3) has the wrong rows", name)
+		}
+	}
+}
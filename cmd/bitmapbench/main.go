@@ -0,0 +1,296 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Command bitmapbench compares the compressed size and the speed of the bitmap implementations on the
+// same data, and prints the results as CSV or JSON.
+//
+// Usage:
+//
+//	bitmapbench [flags] [file...]
+//
+// The data is either generated bitmaps of every size, distribution and density given by the flags,
+// with the generators of the datagen package, or bitmap files as
+// read by bitmaptool, in the format given by -input. The operations on two bitmaps combine each file
+// with the next one. Every bitmap is converted to each implementation before timing.
+//
+// Flags:
+//
+//	-impl ewah,bitset,auto                implementations to compare
+//	-ops and,or,xor,andnot,not,cardinality,get
+//	                                      operations to time
+//	-sizes 10000,1000000                  sizes of the generated bitmaps, in bits
+//	-dist uniform,clustered               distributions of the bits of the generated bitmaps, any of
+//	                                      uniform, zipf, clustered and census
+//	-densities 0.0001,0.01,0.1,0.5        fractions of the bits set in the generated bitmaps
+//	-duration 1s                          minimum time to run each operation
+//	-format csv                           output format, csv or json
+//	-input auto                           format of the bitmap files, auto for files written by
+//	                                      bitmaptool, or ewah or bitset for files without a header
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/auto"
+	"github.com/reducedb/bitmap/bitmaptest"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/cmd/internal/bitmapfile"
+	"github.com/reducedb/bitmap/datagen"
+	"github.com/reducedb/bitmap/ewah"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var implementations = map[string]func() bitmap.Bitmap{
+	"ewah":   ewah.New,
+	"bitset": bitset.New,
+	"auto":   auto.New,
+}
+
+type result struct {
+	Impl        string `json:"impl"`
+	Data        string `json:"data"`
+	Op          string `json:"op"`
+	SizeInBits  int64  `json:"size_in_bits"`
+	Cardinality int64  `json:"cardinality"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+	Iterations  int    `json:"iterations"`
+	NsPerOp     int64  `json:"ns_per_op"`
+	AllocsPerOp int64  `json:"allocs_per_op"`
+	BytesPerOp  int64  `json:"bytes_per_op"`
+}
+
+// dataset is a pair of bitmaps to run the operations on, converted to an implementation
+type dataset struct {
+	name string
+	x, y func(factory func() bitmap.Bitmap) bitmap.Bitmap
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "bitmapbench:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("bitmapbench", flag.ContinueOnError)
+	impls := fs.String("impl", "ewah,bitset,auto", "implementations to compare")
+	ops := fs.String("ops", strings.Join(bitmaptest.BenchOps, ","), "operations to time")
+	sizes := fs.String("sizes", join(bitmaptest.BenchSizes), "sizes of the generated bitmaps, in bits")
+	dists := fs.String("dist", strings.Join(bitmaptest.BenchDistributions, ","), "distributions of the bits of the generated bitmaps")
+	densities := fs.String("densities", join(bitmaptest.BenchDensities), "fractions of the bits set in the generated bitmaps")
+	duration := fs.Duration("duration", time.Second, "minimum time to run each operation")
+	format := fs.String("format", "csv", "output format, csv or json")
+	input := fs.String("input", "auto", "format of the bitmap files: auto, ewah or bitset")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format %s", *format)
+	}
+
+	for _, impl := range strings.Split(*impls, ",") {
+		if implementations[impl] == nil {
+			return fmt.Errorf("unknown implementation %s", impl)
+		}
+	}
+
+	for _, op := range strings.Split(*ops, ",") {
+		if !contains(bitmaptest.BenchOps, op) {
+			return fmt.Errorf("unknown operation %s", op)
+		}
+	}
+
+	var data []dataset
+	var err error
+
+	if fs.NArg() > 0 {
+		data, err = files(fs.Args(), *input)
+	} else {
+		data, err = generate(*sizes, *dists, *densities)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var results []result
+
+	for _, d := range data {
+		for _, impl := range strings.Split(*impls, ",") {
+			x, y := d.x(implementations[impl]), d.y(implementations[impl])
+
+			for _, op := range strings.Split(*ops, ",") {
+				r := measure(bitmaptest.Op(op, x, y), *duration)
+				r.Impl, r.Data, r.Op = impl, d.name, op
+				r.SizeInBits, r.Cardinality, r.SizeInBytes = x.Size(), x.Cardinality(), sizeInBytes(x)
+				results = append(results, r)
+			}
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"impl", "data", "op", "size_in_bits", "cardinality", "size_in_bytes", "iterations", "ns_per_op", "allocs_per_op", "bytes_per_op"})
+
+	for _, r := range results {
+		w.Write([]string{r.Impl, r.Data, r.Op,
+			strconv.FormatInt(r.SizeInBits, 10), strconv.FormatInt(r.Cardinality, 10), strconv.FormatInt(r.SizeInBytes, 10),
+			strconv.Itoa(r.Iterations), strconv.FormatInt(r.NsPerOp, 10), strconv.FormatInt(r.AllocsPerOp, 10), strconv.FormatInt(r.BytesPerOp, 10)})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// measure runs f for at least d, growing the number of iterations like testing.B does
+func measure(f func(int), d time.Duration) result {
+	var before, after runtime.MemStats
+
+	for n := 1; ; {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+
+		if elapsed >= d || n >= 1e9 {
+			return result{
+				Iterations:  n,
+				NsPerOp:     elapsed.Nanoseconds() / int64(n),
+				AllocsPerOp: int64(after.Mallocs-before.Mallocs) / int64(n),
+				BytesPerOp:  int64(after.TotalAlloc-before.TotalAlloc) / int64(n),
+			}
+		}
+
+		// Aim for d with some room to spare, but grow at most 100 times per round
+		next := int(float64(n) * 1.2 * float64(d) / float64(elapsed+1))
+		if next > 100*n {
+			next = 100 * n
+		}
+		if next <= n {
+			next = n + 1
+		}
+		n = next
+	}
+}
+
+func generate(sizes, dists, densities string) ([]dataset, error) {
+	var data []dataset
+
+	for _, s := range strings.Split(sizes, ",") {
+		size, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size %s", s)
+		}
+
+		for _, dist := range strings.Split(dists, ",") {
+			g := datagen.Named(dist)
+			if g == nil {
+				return nil, fmt.Errorf("unknown distribution %s", dist)
+			}
+
+			for _, v := range strings.Split(densities, ",") {
+				density, err := strconv.ParseFloat(v, 64)
+				if err != nil || density <= 0 || density > 1 {
+					return nil, fmt.Errorf("invalid density %s", v)
+				}
+
+				// The same seeds give every implementation the same bits
+				x, y := datagen.Generate(g, size, size, density), datagen.Generate(g, size+1, size, density)
+
+				data = append(data, dataset{
+					name: fmt.Sprintf("size=%d/dist=%s/density=%g", size, dist, density),
+					x:    build(x),
+					y:    build(y),
+				})
+			}
+		}
+	}
+
+	return data, nil
+}
+
+func build(pos []int64) func(func() bitmap.Bitmap) bitmap.Bitmap {
+	return func(factory func() bitmap.Bitmap) bitmap.Bitmap {
+		return datagen.Bitmap(factory, pos)
+	}
+}
+
+func files(names []string, format string) ([]dataset, error) {
+	bms := make([]bitmap.Bitmap, len(names))
+	for i, name := range names {
+		bm, err := bitmapfile.Load(name, format)
+		if err != nil {
+			return nil, err
+		}
+		bms[i] = bm
+	}
+
+	convert := func(src bitmap.Bitmap) func(func() bitmap.Bitmap) bitmap.Bitmap {
+		return func(factory func() bitmap.Bitmap) bitmap.Bitmap {
+			return bitmapfile.Convert(src, factory)
+		}
+	}
+
+	data := make([]dataset, len(names))
+	for i, name := range names {
+		data[i] = dataset{
+			name: name,
+			x:    convert(bms[i]),
+			y:    convert(bms[(i+1)%len(bms)]),
+		}
+	}
+
+	return data, nil
+}
+
+func sizeInBytes(bm bitmap.Bitmap) int64 {
+	if s, ok := bm.(interface{ Stats() bitmap.Stats }); ok {
+		return s.Stats().SizeInBytes
+	}
+
+	return 0
+}
+
+func join[T int64 | float64](a []T) string {
+	s := make([]string, len(a))
+	for i, v := range a {
+		s[i] = fmt.Sprint(v)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/cmd/internal/bitmapfile"
+	"github.com/reducedb/bitmap/ewah"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	args := []string{"-sizes", "1000", "-densities", "0.01,0.5", "-ops", "and,cardinality", "-duration", "1ms"}
+
+	if err := run(args, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A header, then 2 distributions and 2 densities for 3 implementations and 2 operations
+	if len(records) != 1+2*2*3*2 || records[1][0] != "ewah" || records[1][2] != "and" {
+		t.Fatalf("Unexpected CSV output: %v", records)
+	}
+
+	out.Reset()
+	if err := run(append(args, "-format", "json", "-impl", "bitset"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []result
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	// A bitset takes one word per 64 bits
+	if len(results) != 8 || results[0].SizeInBytes != 8*int64((results[0].SizeInBits+63)/64) || results[0].Iterations == 0 {
+		t.Fatalf("Unexpected JSON output: %+v", results)
+	}
+
+	for _, args := range [][]string{{"-impl", "roaring"}, {"-ops", "nand"}, {"-sizes", "x"}, {"-densities", "2"}, {"-dist", "normal"}, {"-format", "xml"}, {"missing.bm"}} {
+		if run(args, &out) == nil {
+			t.Fatalf("%v should fail", args)
+		}
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+
+	if err := bitmapfile.Save(a, ewah.New().Set(1).Set(100).Set(5000)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bitmapfile.Save(b, bitset.New().Set(100).Set(200)); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"-ops", "and", "-duration", "1ms", "-format", "json", a, b}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []result
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 files for 3 implementations, each converted with the bits of its file
+	if len(results) != 6 || results[0].Data != a || results[0].Cardinality != 3 || results[3].Data != b || results[3].Cardinality != 2 {
+		t.Fatalf("Unexpected JSON output: %+v", results)
+	}
+
+	if run([]string{"-input", "bitset", a}, &out) == nil {
+		t.Fatal("a file with a header should fail with -input bitset")
+	}
+}
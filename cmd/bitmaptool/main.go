@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Command bitmaptool inspects and combines bitmap files, as written by the MarshalBinary methods of
+// EWAH bitmaps and bitsets.
+//
+// Usage:
+//
+//	bitmaptool stats [-details] file...    print how each bitmap is stored
+//	bitmaptool dump file...                print the marker and literal words of EWAH bitmaps
+//	bitmaptool list file...                print the positions of the bits set, after a name:
+//	                                       line for each file if there are several
+//	bitmaptool card file...                print the number of bits set
+//	bitmaptool and -o out file file...     combine bitmaps with and, or, xor or andnot
+//
+// The files written by bitmaptool start with a header byte, e for EWAH or b for bitset, followed by
+// the MarshalBinary data. Every command takes a -format flag, which is auto (the default) to read
+// that header, or ewah or bitset to read a file with only the MarshalBinary data, as written by the
+// library. When combining bitmaps of different formats, the result has the format of the first one.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/cmd/internal/bitmapfile"
+	"github.com/reducedb/bitmap/ewah"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "bitmaptool:", err)
+		os.Exit(1)
+	}
+}
+
+const usage = "usage: bitmaptool stats|dump|list|card|and|or|xor|andnot [flags] file..."
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	cmd := args[0]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "auto", "file format: auto for files with a header, ewah or bitset for files without")
+	details := fs.Bool("details", false, "stats: also print the histogram of running lengths")
+	output := fs.String("o", "", "and, or, xor, andnot: file to write the result to")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%s: %v", cmd, err)
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New(usage)
+	}
+
+	bms := make([]bitmap.Bitmap, fs.NArg())
+	for i, name := range fs.Args() {
+		bm, err := bitmapfile.Load(name, *format)
+		if err != nil {
+			return err
+		}
+		bms[i] = bm
+	}
+
+	switch cmd {
+	case "stats":
+		for i, bm := range bms {
+			stats(out, fs.Arg(i), bm, *details)
+		}
+	case "dump":
+		for i, bm := range bms {
+			if err := dump(out, fs.Arg(i), bm); err != nil {
+				return err
+			}
+		}
+	case "list":
+		for k, bm := range bms {
+			if len(bms) > 1 {
+				fmt.Fprintf(out, "%s:\n", fs.Arg(k))
+			}
+
+			for i := range bitmap.All(bm) {
+				fmt.Fprintln(out, i)
+			}
+		}
+	case "card":
+		for i, bm := range bms {
+			fmt.Fprintf(out, "%s: %d\n", fs.Arg(i), bm.Cardinality())
+		}
+	case "and", "or", "xor", "andnot":
+		if *output == "" || len(bms) < 2 {
+			return fmt.Errorf("%s: needs -o and at least two files", cmd)
+		}
+
+		return bitmapfile.Save(*output, combine(cmd, bms))
+	default:
+		return fmt.Errorf("unknown command %s\n%s", cmd, usage)
+	}
+
+	return nil
+}
+
+// combine applies the operation to the bitmaps, converting them to the format of the first one
+func combine(op string, bms []bitmap.Bitmap) bitmap.Bitmap {
+	factory := ewah.New
+	if bitmapfile.Format(bms[0]) == "bitset" {
+		factory = bitset.New
+	}
+
+	a := make([]bitmap.Bitmap, len(bms)-1)
+	for i, bm := range bms[1:] {
+		a[i] = bitmapfile.Convert(bm, factory)
+	}
+
+	switch op {
+	case "and":
+		return bms[0].And(a...)
+	case "or":
+		return bms[0].Or(a...)
+	case "xor":
+		return bms[0].Xor(a...)
+	}
+
+	return bms[0].AndNot(a...)
+}
+
+func stats(out io.Writer, name string, bm bitmap.Bitmap, details bool) {
+	s := bm.(interface{ Stats() bitmap.Stats }).Stats()
+	fmt.Fprintf(out, "%s (%s): %v\n", name, bitmapfile.Format(bm), s)
+
+	if !details {
+		return
+	}
+
+	for i, n := range s.RunLengths {
+		if n > 0 {
+			fmt.Fprintf(out, "  runs of %d-%d words: %d\n", int64(1)<<uint(i), int64(1)<<uint(i+1)-1, n)
+		}
+	}
+}
+
+func dump(out io.Writer, name string, bm bitmap.Bitmap) error {
+	e, ok := bm.(*ewah.Ewah)
+	if !ok {
+		return fmt.Errorf("%s: dump only works on EWAH bitmaps", name)
+	}
+
+	fmt.Fprintf(out, "%s: %d bits\n", name, e.Size())
+
+	for it, m := e.WordIterator(), 0; it.Next(); m++ {
+		bit, count := it.Run()
+		literals := it.Literals()
+
+		v := 0
+		if bit {
+			v = 1
+		}
+
+		fmt.Fprintf(out, "marker %d at word %d: %d words of %d's, %d literal words\n", m, it.Offset(), count, v, len(literals))
+
+		for i, w := range literals {
+			fmt.Fprintf(out, "  %8d: %064b\n", it.Offset()+count+int64(i), w)
+		}
+	}
+
+	return nil
+}
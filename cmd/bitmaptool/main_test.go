@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/cmd/internal/bitmapfile"
+	"github.com/reducedb/bitmap/ewah"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c, r := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c"), filepath.Join(dir, "r")
+
+	bm := ewah.New()
+	for _, i := range []int64{1, 64, 65, 1000} {
+		bm.Set(i)
+	}
+	for i := int64(2000); i < 2200; i++ {
+		bm.Set(i)
+	}
+
+	if err := bitmapfile.Save(a, bm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bitmapfile.Save(b, bitset.New().Set(64).Set(2100).Set(3000)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file written by the library has no header
+	raw, err := bitset.New().Set(5).Set(7).(*bitset.Bitset).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(r, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		ans  string
+	}{
+		{[]string{"card", a, b}, a + ": 204\n" + b + ": 3\n"},
+		{[]string{"list", b}, "64\n2100\n3000\n"},
+		{[]string{"list", "-format", "bitset", r}, "5\n7\n"},
+		{[]string{"and", "-o", c, a, b}, ""},
+		{[]string{"list", c}, "64\n2100\n"},
+		{[]string{"andnot", "-o", c, b, a}, ""},
+		{[]string{"list", c}, "3000\n"},
+		{[]string{"list", b, c}, b + ":\n64\n2100\n3000\n" + c + ":\n3000\n"},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := run(test.args, &out); err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+
+		if out.String() != test.ans {
+			t.Fatalf("%v printed %q, should print %q", test.args, out.String(), test.ans)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"list", a}, &out); err != nil || strings.Count(out.String(), "\n") != 204 {
+		t.Fatalf("list printed %d positions, should print 204", strings.Count(out.String(), "\n"))
+	}
+
+	out.Reset()
+	if err := run([]string{"stats", "-details", a}, &out); err != nil || !strings.Contains(out.String(), "cardinality = 204") {
+		t.Fatalf("stats printed %q", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"dump", a}, &out); err != nil || !strings.Contains(out.String(), "words of 1's") {
+		t.Fatalf("dump printed %q", out.String())
+	}
+
+	for _, args := range [][]string{{}, {"card"}, {"bogus", a}, {"and", a, b}, {"dump", b}, {"card", "-format", "ewah", r}, {"card", r}, {"card", "-format", "roaring", r}} {
+		if run(args, &out) == nil {
+			t.Fatalf("%v should fail", args)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Package bitmapfile reads and writes the bitmap files of the bitmaptool and bitmapbench commands.
+// A file starts with a header byte, e for EWAH or b for bitset, followed by the MarshalBinary data.
+// Files written by the library itself have no header, and are read by giving their format
+// explicitly.
+package bitmapfile
+
+import (
+	"encoding"
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/ewah"
+	"os"
+)
+
+// headers are the first byte of the files written by Save, giving the format of the rest
+var headers = map[byte]string{'e': "ewah", 'b': "bitset"}
+
+// Load reads a bitmap file in the given format, ewah or bitset for a file with only the MarshalBinary
+// data, or auto for a file that starts with the header byte written by Save.
+func Load(name, format string) (bitmap.Bitmap, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "auto" {
+		if len(data) == 0 || headers[data[0]] == "" {
+			return nil, fmt.Errorf("%s: no bitmap file header, the format must be ewah or bitset", name)
+		}
+		format, data = headers[data[0]], data[1:]
+	}
+
+	var bm bitmap.Bitmap
+	switch format {
+	case "ewah":
+		bm = ewah.New()
+	case "bitset":
+		bm = bitset.New()
+	default:
+		return nil, fmt.Errorf("unknown format %s", format)
+	}
+
+	if err = bm.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return bm, nil
+}
+
+// Save writes a bitmap file with a header byte for the format of bm, then the MarshalBinary data
+func Save(name string, bm bitmap.Bitmap) error {
+	data, err := bm.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	header := byte('b')
+	if Format(bm) == "ewah" {
+		header = 'e'
+	}
+
+	return os.WriteFile(name, append([]byte{header}, data...), 0644)
+}
+
+// Format returns ewah for an EWAH bitmap, and bitset for anything else
+func Format(bm bitmap.Bitmap) string {
+	if _, ok := bm.(*ewah.Ewah); ok {
+		return "ewah"
+	}
+
+	return "bitset"
+}
+
+// Convert returns a copy of src, an EWAH bitmap or a bitset as returned by Load, in the implementation
+// created by factory. EWAH bitmaps and bitsets are converted to each other word by word, and any other
+// implementation copies src as is.
+func Convert(src bitmap.Bitmap, factory func() bitmap.Bitmap) bitmap.Bitmap {
+	dst := factory()
+
+	switch dst.(type) {
+	case *ewah.Ewah:
+		if b, ok := src.(*bitset.Bitset); ok {
+			return ewah.FromBitset(b)
+		}
+	case *bitset.Bitset:
+		if e, ok := src.(*ewah.Ewah); ok {
+			return bitset.FromEwah(e)
+		}
+	default:
+		return dst.Copy(src)
+	}
+
+	return src.Clone()
+}
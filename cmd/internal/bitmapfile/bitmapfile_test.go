@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmapfile
+
+import (
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/auto"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/ewah"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSave(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, bm := range []bitmap.Bitmap{ewah.New().Set(3).Set(1000), bitset.New().Set(3).Set(1000)} {
+		name := filepath.Join(dir, Format(bm))
+		if err := Save(name, bm); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Load(name, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if Format(got) != Format(bm) || !got.Equal(bm) {
+			t.Fatalf("Load(%s) = %v, expected %v", name, got, bm)
+		}
+	}
+
+	// Files without a header need their format, and the format isn't guessed
+	raw, err := ewah.New().Set(5).(*ewah.Ewah).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(dir, "raw")
+	if err := os.WriteFile(name, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := Load(name, "ewah"); err != nil || !got.Get(5) || got.Cardinality() != 1 {
+		t.Fatalf("Load(raw, ewah) = %v, %v", got, err)
+	}
+
+	for _, format := range []string{"bitset", "roaring"} {
+		if _, err := Load(name, format); err == nil {
+			t.Fatalf("Load(raw, %s) should fail", format)
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing"), "auto"); err == nil {
+		t.Fatal("Load(missing) should fail")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	e := ewah.New()
+	for i := int64(0); i < 300; i += 7 {
+		e.Set(i)
+	}
+
+	b := Convert(e, bitset.New)
+	if _, ok := b.(*bitset.Bitset); !ok || !bitmap.SameBits(b, e) {
+		t.Fatalf("Convert(ewah, bitset) = %v", b)
+	}
+
+	if c := Convert(b, ewah.New); Format(c) != "ewah" || !c.Equal(e) {
+		t.Fatalf("Convert(bitset, ewah) = %v", c)
+	}
+
+	if c := Convert(e, ewah.New); c == e || !c.Equal(e) {
+		t.Fatal("Convert(ewah, ewah) should return a copy")
+	}
+
+	if a := Convert(b, auto.New); !bitmap.SameBits(a, e) {
+		t.Fatalf("Convert(bitset, auto) = %v", a)
+	}
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap
+
+// appender is implemented by bitmaps that can add the bits of another bitmap after their own
+type appender interface {
+	Append(Bitmap) Bitmap
+}
+
+// Concat returns a new bitmap with the bits of each bitmap placed right after the bits of the ones
+// before it, where each bitmap takes up Size() bits. The result has the implementation of the first
+// bitmap. Implementations with an Append method, like EWAH bitmaps and bitsets, append whole words;
+// others are copied one bit at a time, in which case the size of the result ends at the last bit set.
+func Concat(a ...Bitmap) Bitmap {
+	if len(a) == 0 {
+		return nil
+	}
+
+	ans := a[0].Clone()
+	offset := a[0].Size()
+
+	for _, b := range a[1:] {
+		if ap, ok := ans.(appender); ok {
+			if ans = ap.Append(b); ans == nil {
+				return nil
+			}
+		} else {
+			for i, n := int64(0), b.Size(); i < n; i++ {
+				if b.Get(i) {
+					ans.Set(offset + i)
+				}
+			}
+		}
+
+		offset += b.Size()
+	}
+
+	return ans
+}
@@ -0,0 +1,24 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap
+
+// Convert copies the bits of src into a new bitmap created by factory. Bits are copied one at a
+// time in ascending order, so it works between any two implementations, including ones like EWAH
+// that only accept ascending Sets. The result ends at the last bit set in src, so its Size may be
+// smaller than src.Size(). The ewah and bitset packages convert between each other word by word
+// with ewah.FromBitset and bitset.FromEwah, which is much faster.
+func Convert(src Bitmap, factory func() Bitmap) Bitmap {
+	dst := factory()
+
+	for i, n := int64(0), src.Size(); i < n; i++ {
+		if src.Get(i) {
+			dst.Set(i)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Package datagen generates bitmaps for tests and benchmarks. How well a bitmap compresses depends
+// on how its bits are clustered much more than on how many there are, so the generators cover
+// uniform, skewed, clustered and sorted data, all from a seed so the data can be reproduced:
+//
+//	pos := datagen.Generate(datagen.Clustered(64), 42, 1000000, 0.01)
+//	bm := datagen.Bitmap(ewah.New, pos)
+package datagen
+
+import (
+	"github.com/reducedb/bitmap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Generator returns the positions of the bits set in a bitmap of size bits, in ascending order, with
+// about density of the bits set.
+type Generator func(r *rand.Rand, size int64, density float64) []int64
+
+// Generate runs a generator with a random source seeded with seed.
+func Generate(g Generator, seed int64, size int64, density float64) []int64 {
+	return g(rand.New(rand.NewSource(seed)), size, density)
+}
+
+// Bitmap returns a new bitmap created by factory with the bits at the positions set. The positions
+// must be in ascending order.
+func Bitmap(factory func() bitmap.Bitmap, positions []int64) bitmap.Bitmap {
+	bm := factory()
+	for _, i := range positions {
+		bm.Set(i)
+	}
+
+	return bm
+}
+
+// Names are the names of the generators Named knows about.
+var Names = []string{"uniform", "zipf", "clustered", "census"}
+
+// Named returns a generator by name, with default parameters: Zipf(1.1), Clustered(64) and
+// Census(1). It returns nil for unknown names.
+func Named(name string) Generator {
+	switch name {
+	case "uniform":
+		return Uniform
+	case "zipf":
+		return Zipf(1.1)
+	case "clustered":
+		return Clustered(64)
+	case "census":
+		return Census(1)
+	}
+
+	return nil
+}
+
+// Uniform sets every bit independently with probability density.
+func Uniform(r *rand.Rand, size int64, density float64) []int64 {
+	var pos []int64
+
+	for i := geometric(r, density); i < size; i += 1 + geometric(r, density) {
+		pos = append(pos, i)
+	}
+
+	return pos
+}
+
+// Zipf sets bits at positions drawn from a Zipf distribution with exponent s > 1, so the bits are
+// packed at the start of the bitmap and get sparser and sparser. Positions are drawn until density
+// of the bits are set, or until too many draws hit bits already set, in which case the density is
+// lower.
+func Zipf(s float64) Generator {
+	return func(r *rand.Rand, size int64, density float64) []int64 {
+		if size <= 0 {
+			return nil
+		}
+
+		z := rand.NewZipf(r, s, 1, uint64(size-1))
+		n := int64(density * float64(size))
+		set := make(map[int64]bool, n)
+
+		for tries := 10 * n; int64(len(set)) < n && tries > 0; tries-- {
+			set[int64(z.Uint64())] = true
+		}
+
+		pos := make([]int64, 0, len(set))
+		for i := range set {
+			pos = append(pos, i)
+		}
+
+		sort.Slice(pos, func(i, j int) bool { return pos[i] < pos[j] })
+		return pos
+	}
+}
+
+// Clustered sets runs of bits from a two state Markov chain: runs of 1's are meanRun bits long on
+// average, and the runs of 0's in between are as long as needed for density of the bits to be set.
+func Clustered(meanRun float64) Generator {
+	return func(r *rand.Rand, size int64, density float64) []int64 {
+		if density >= 1 {
+			return Uniform(r, size, density)
+		}
+
+		if meanRun < 1 {
+			meanRun = 1
+		}
+
+		var pos []int64
+
+		// The probabilities of a run ending after each bit
+		endOne, endZero := 1/meanRun, density/(meanRun*(1-density))
+
+		for i := geometric(r, endZero); i < size; i += 1 + geometric(r, endZero) {
+			for n := 1 + geometric(r, endOne); n > 0 && i < size; n, i = n-1, i+1 {
+				pos = append(pos, i)
+			}
+		}
+
+		return pos
+	}
+}
+
+// Census sets bits like the bitmap of a value of a column of a table sorted on its columns, like
+// the census data sets bitmap indexes are often measured on. The table is sorted on column 0 first,
+// so a value of column 0 is one long run. Each later column is split into blocks by the values of the
+// columns before it, with about 1/density values each, and a value of the column is a run in each
+// block, at about the same place since the blocks are sorted too.
+func Census(column int) Generator {
+	return func(r *rand.Rand, size int64, density float64) []int64 {
+		if density >= 1 {
+			return Uniform(r, size, density)
+		}
+
+		var pos []int64
+
+		// Each column before this one has about 1/density values, which split the rows in blocks
+		block := float64(size) * math.Pow(density, float64(column))
+		place := r.Float64() * (1 - density)
+
+		for start := int64(0); start < size; {
+			// Blocks vary in size, like the number of rows with each value, except for column 0 where
+			// the whole table is one block
+			n := int64(block * (0.5 + r.Float64()))
+			if column == 0 {
+				n = size
+			} else if n < 1 {
+				n = 1
+			}
+
+			// Rounding at random keeps the density right when blocks are shorter than 1/density
+			run := int64(float64(n)*density + r.Float64())
+			first := start + int64(float64(n)*place)
+
+			for i := first; i < first+run && i < start+n && i < size; i++ {
+				pos = append(pos, i)
+			}
+
+			start += n
+		}
+
+		return pos
+	}
+}
+
+// geometric returns the number of failures before the first success of trials with probability p
+func geometric(r *rand.Rand, p float64) int64 {
+	if p >= 1 {
+		return 0
+	}
+
+	if p <= 0 {
+		return math.MaxInt64 / 2
+	}
+
+	return int64(math.Log(1-r.Float64()) / math.Log(1-p))
+}
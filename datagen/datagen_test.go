@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package datagen
+
+import (
+	"github.com/reducedb/bitmap/ewah"
+	"math"
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	generators := map[string]Generator{
+		"uniform":   Uniform,
+		"zipf":      Zipf(1.1),
+		"clustered": Clustered(64),
+		"census0":   Census(0),
+		"census2":   Census(2),
+	}
+
+	const size = 1000000
+
+	for name, g := range generators {
+		for _, density := range []float64{0.001, 0.01, 0.1, 0.5} {
+			pos := Generate(g, 1, size, density)
+
+			for i := range pos {
+				if pos[i] < 0 || pos[i] >= size || (i > 0 && pos[i] <= pos[i-1]) {
+					t.Fatalf("%s: positions should be ascending and within the size, got %d", name, pos[i])
+				}
+			}
+
+			// Zipf can't always place enough distinct bits on dense bitmaps
+			d := float64(len(pos)) / size
+			if math.Abs(d-density) > density/4 && !(name == "zipf" && d < density) {
+				t.Fatalf("%s: density %g, should be about %g", name, d, density)
+			}
+
+			again := Generate(g, 1, size, density)
+			if len(again) != len(pos) || (len(pos) > 0 && again[len(pos)-1] != pos[len(pos)-1]) {
+				t.Fatalf("%s: the same seed should generate the same bitmap", name)
+			}
+
+			bm := Bitmap(ewah.New, pos)
+			if bm.Cardinality() != int64(len(pos)) {
+				t.Fatalf("%s: cardinality %d != %d", name, bm.Cardinality(), len(pos))
+			}
+		}
+	}
+
+	// Clustered bits compress much better than uniform ones of the same density
+	uniform := Bitmap(ewah.New, Generate(Uniform, 1, size, 0.01)).(*ewah.Ewah)
+	clustered := Bitmap(ewah.New, Generate(Clustered(1000), 1, size, 0.01)).(*ewah.Ewah)
+	if clustered.SizeInWords()*10 > uniform.SizeInWords() {
+		t.Fatalf("Clustered bitmap takes %d words, uniform one %d", clustered.SizeInWords(), uniform.SizeInWords())
+	}
+}
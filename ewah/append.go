@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"github.com/reducedb/bitmap"
+	"math"
+)
+
+// Append adds the bits of other after the end of this bitmap, so bit i of other becomes bit Size()+i,
+// and the size grows by other.Size(). If the size is a multiple of 64, the marker words of other are
+// copied over as they are; otherwise the words of other are shifted to line up with the last word of
+// this bitmap. Bitmaps that are not EWAH bitmaps are converted first. It returns nil if the new size
+// is more than an EWAH bitmap can hold.
+func (this *Ewah) Append(other bitmap.Bitmap) bitmap.Bitmap {
+	o := asEwah(other)
+	if o == this {
+		o = this.Clone().(*Ewah)
+	}
+
+	size := this.sizeInBits + o.sizeInBits
+	if size > math.MaxInt32 {
+		return nil
+	}
+
+	c := newCursor(o.buffer, o.actualSizeInWords)
+
+	r := uint64(this.sizeInBits % wordInBits)
+	if r == 0 {
+		for {
+			this.addStreamOfEmptyWords(c.emptyBit(), c.emptyCount())
+			this.addStreamOfLiteralWords(o.buffer, int32(c.marker+1), int32(c.literalCount()))
+
+			if c.lastMarker() || c.nextMarker() != nil {
+				break
+			}
+		}
+
+		this.sizeInBits = size
+
+		return this
+	}
+
+	// The last word is only partially used, so it's taken out and the first bits of other are added
+	// to it
+	carry := this.removeLastWord()
+	this.sizeInBits -= int64(r)
+
+	w := newWordWriter(this, size)
+
+	for {
+		word, m := c.current()
+		if m == 0 {
+			break
+		}
+
+		w.write(word<<r|carry, 1)
+		w.write(word, m-1)
+		carry = word >> (uint64(wordInBits) - r)
+
+		c.moveForward(m)
+	}
+
+	w.write(carry, 1)
+
+	return w.done()
+}
@@ -12,110 +12,74 @@ import (
 )
 
 func (this *Ewah) And(a ...bitmap.Bitmap) bitmap.Bitmap {
-	b, ok := a[0].(*Ewah)
+	if len(a) > 1 {
+		return multiway(andOp, append([]bitmap.Bitmap{this}, a...))
+	}
+
+	b, ok := toEwah(a[0])
 	if !ok {
 		return nil
 	}
 
 	ans := New().(*Ewah)
-	tmp := New().(*Ewah)
 	ans.reserve(int32(math.Max(float64(this.actualSizeInWords), float64(b.actualSizeInWords))))
-	tmp.reserve(int32(math.Max(float64(this.actualSizeInWords), float64(b.actualSizeInWords))))
 
 	this.andToContainer(b, ans)
 
-	for _, v := range a[1:] {
-		b, ok := v.(*Ewah)
-		if !ok {
-			return nil
-		}
-
-		ans.andToContainer(b, tmp)
-		tmp.Swap(ans)
-		tmp.Reset()
-	}
-
 	return ans
 }
 
 func (this *Ewah) AndNot(a ...bitmap.Bitmap) bitmap.Bitmap {
-	b, ok := a[0].(*Ewah)
+	if len(a) > 1 {
+		return multiway(andNotOp, append([]bitmap.Bitmap{this}, a...))
+	}
+
+	b, ok := toEwah(a[0])
 	if !ok {
 		return nil
 	}
 
 	ans := New().(*Ewah)
-	tmp := New().(*Ewah)
 	ans.reserve(int32(math.Max(float64(this.actualSizeInWords), float64(b.actualSizeInWords))))
-	tmp.reserve(int32(math.Max(float64(this.actualSizeInWords), float64(b.actualSizeInWords))))
 
 	this.andNotToContainer(b, ans)
 
-	for _, v := range a[1:] {
-		b, ok := v.(*Ewah)
-		if !ok {
-			return nil
-		}
-
-		ans.andNotToContainer(b, tmp)
-		tmp.Swap(ans)
-		tmp.Reset()
-	}
-
 	return ans
 }
 
 func (this *Ewah) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
-	b, ok := a[0].(*Ewah)
+	if len(a) > 1 {
+		return multiway(orOp, append([]bitmap.Bitmap{this}, a...))
+	}
+
+	b, ok := toEwah(a[0])
 	if !ok {
 		return nil
 	}
 
 	ans := New().(*Ewah)
-	tmp := New().(*Ewah)
 	ans.reserve(int32(math.Max(float64(this.actualSizeInWords), float64(b.actualSizeInWords))))
-	tmp.reserve(int32(math.Max(float64(this.actualSizeInWords), float64(b.actualSizeInWords))))
 
 	this.orToContainer(b, ans)
 
-	for _, v := range a[1:] {
-		b, ok := v.(*Ewah)
-		if !ok {
-			return nil
-		}
-
-		ans.orToContainer(b, tmp)
-		tmp.Swap(ans)
-		tmp.Reset()
-	}
-
 	return ans
 }
 
 func (this *Ewah) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
-	b, ok := a[0].(*Ewah)
+	if len(a) > 1 {
+		return multiway(xorOp, append([]bitmap.Bitmap{this}, a...))
+	}
+
+	b, ok := toEwah(a[0])
 	if !ok {
 		return nil
 	}
 
 	ans := New().(*Ewah)
-	tmp := New().(*Ewah)
 	ans.reserve(int32(math.Max(float64(this.actualSizeInWords), float64(b.actualSizeInWords))))
-	tmp.reserve(int32(math.Max(float64(this.actualSizeInWords), float64(b.actualSizeInWords))))
 
 	this.xorToContainer(b, ans)
 
-	for _, v := range a[1:] {
-		b, ok := v.(*Ewah)
-		if !ok {
-			return nil
-		}
-
-		ans.xorToContainer(b, tmp)
-		tmp.Swap(ans)
-		tmp.Reset()
-	}
-
 	return ans
 }
 
@@ -145,14 +109,24 @@ func (this *Ewah) Not() bitmap.Bitmap {
 			// the word
 			if c.literalCount() == 0 {
 				if c.emptyCount() > 0 && c.emptyBit() {
-					c.setLiteralCount(int64(c.literalCount()) - 1)
-					this.addLiteralWord(uint64(0) >> uint64(wordInBits-lastBits))
+					this.setCursor.updateMarkerCounts()
+					this.replaceLastEmptyWord(^uint64(0) >> uint64(wordInBits-lastBits))
 				}
 
 				break
 			}
 
-			this.buffer[c.marker+c.literalRemaining()] &= ^uint64(0) >> uint64(wordInBits-lastBits)
+			last := c.marker + c.literalRemaining()
+			this.buffer[last] &= ^uint64(0) >> uint64(wordInBits-lastBits)
+
+			// If the populated portion was all 1's, the last word is now empty and shouldn't stay a literal
+			if this.buffer[last] == 0 {
+				c.setLiteralCount(c.literalCount() - 1)
+				this.actualSizeInWords -= 1
+				this.setCursor.updateMarkerCounts()
+				this.addEmptyWord(false)
+			}
+
 			break
 		}
 
@@ -161,6 +135,10 @@ func (this *Ewah) Not() bitmap.Bitmap {
 		}
 	}
 
+	// The marker words have changed under the cursors
+	this.setCursor.quickUpdate(this.buffer, this.actualSizeInWords)
+	this.getCursor.quickUpdate(this.buffer, this.actualSizeInWords)
+
 	return this
 }
 
@@ -495,3 +473,27 @@ func (this *Ewah) xorCardinality(a *Ewah) int32 {
 	this.xorToContainer(a, counter)
 	return int32(counter.(*bitCounter).getCount())
 }
+
+// AndCardinality returns the number of bits set in both this bitmap and other, without creating the
+// result of And.
+func (this *Ewah) AndCardinality(other bitmap.Bitmap) int64 {
+	return int64(this.andCardinality(asEwah(other)))
+}
+
+// OrCardinality returns the number of bits set in either this bitmap or other, without creating the
+// result of Or.
+func (this *Ewah) OrCardinality(other bitmap.Bitmap) int64 {
+	return int64(this.orCardinality(asEwah(other)))
+}
+
+// AndNotCardinality returns the number of bits set in this bitmap but not in other, without creating
+// the result of AndNot.
+func (this *Ewah) AndNotCardinality(other bitmap.Bitmap) int64 {
+	return int64(this.andNotCardinality(asEwah(other)))
+}
+
+// XorCardinality returns the number of bits set in only one of this bitmap and other, without
+// creating the result of Xor.
+func (this *Ewah) XorCardinality(other bitmap.Bitmap) int64 {
+	return int64(this.xorCardinality(asEwah(other)))
+}
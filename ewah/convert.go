@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+)
+
+// FromWords creates an EWAH bitmap of sizeInBits bits from uncompressed 64-bit words, where bit i is
+// stored in words[i/64] at position i%64. Words that are all 0's or all 1's become running lengths,
+// and everything else is copied over as literal words. Bits past sizeInBits are dropped.
+func FromWords(words []uint64, sizeInBits int64) bitmap.Bitmap {
+	ewah := New().(*Ewah)
+
+	if sizeInBits <= 0 {
+		return ewah
+	}
+
+	n := (sizeInBits + wordInBits - 1) / wordInBits
+	if int64(len(words)) > n {
+		words = words[:n]
+	}
+
+	// The last word may only be partially used, so we mask it separately to make sure we don't
+	// turn the unused bits into a running length of 1's.
+	last := int64(len(words))
+	if lastBits := sizeInBits % wordInBits; lastBits != 0 && last == n {
+		last -= 1
+	}
+
+	for i := int64(0); i < last; {
+		j := i + 1
+
+		if w := words[i]; w == 0 || w == ^uint64(0) {
+			for j < last && words[j] == w {
+				j += 1
+			}
+
+			ewah.addStreamOfEmptyWords(w != 0, j-i)
+		} else {
+			for j < last && words[j] != 0 && words[j] != ^uint64(0) {
+				j += 1
+			}
+
+			ewah.addStreamOfLiteralWords(words, int32(i), int32(j-i))
+		}
+
+		i = j
+	}
+
+	if last < int64(len(words)) {
+		ewah.add(words[last] & (^uint64(0) >> uint64(wordInBits-sizeInBits%wordInBits)))
+	}
+
+	// Pad with 0's if there were fewer words than the size requires
+	if missing := n - (ewah.sizeInBits+wordInBits-1)/wordInBits; missing > 0 {
+		ewah.addStreamOfEmptyWords(false, missing)
+	}
+
+	ewah.sizeInBits = sizeInBits
+
+	return ewah
+}
+
+// FromBitset creates an EWAH bitmap from a bitset, word by word.
+func FromBitset(b *bitset.Bitset) bitmap.Bitmap {
+	return FromWords(b.Words(), b.Size())
+}
+
+// Words uncompresses the bitmap into a new slice of 64-bit words, where bit i is stored in word
+// i/64 at position i%64. Literal words are copied as is, and running lengths are expanded.
+func (this *Ewah) Words() []uint64 {
+	words := make([]uint64, (this.sizeInBits+wordInBits-1)/wordInBits)
+	n := int64(0)
+
+	c := newCursor(this.buffer, this.actualSizeInWords)
+
+	for n < int64(len(words)) {
+		run := c.emptyCount()
+		if n+run > int64(len(words)) {
+			run = int64(len(words)) - n
+		}
+
+		if c.emptyBit() {
+			for k := n; k < n+run; k++ {
+				words[k] = ^uint64(0)
+			}
+		}
+
+		n += run
+		n += int64(copy(words[n:], this.buffer[c.marker+1:c.marker+1+c.literalCount()]))
+
+		if c.lastMarker() || c.nextMarker() != nil {
+			break
+		}
+	}
+
+	if lastBits := this.sizeInBits % wordInBits; lastBits != 0 {
+		words[len(words)-1] &= ^uint64(0) >> uint64(wordInBits-lastBits)
+	}
+
+	return words
+}
@@ -52,11 +52,17 @@ func (this *cursor) reset(a []uint64, s int64) {
 	this.resetMarker(a, s, 0)
 }
 
-// quickUpdate only updates the buffer and buffer size without changing anything else
+// quickUpdate updates the buffer and buffer size, and goes back to the beginning of the current
+// marker since the marker word may have changed. The number of words checked stays accurate, so
+// we don't have to start over from the beginning of the buffer.
 func (this *cursor) quickUpdate(a []uint64, s int64) {
 	this.buffer = a
 	this.bsize = s
 
+	this.totalChecked -= this.emptyChecked + this.literalChecked
+	this.emptyChecked = 0
+	this.literalChecked = 0
+
 	this.updateMarkerCounts()
 }
 
@@ -82,7 +88,7 @@ func (this *cursor) resetMarker(a []uint64, s int64, m int64) {
 }
 
 func (this *cursor) nextMarker() error {
-	if this.end() {
+	if this.lastMarker() {
 		return errors.New("cursor.go/nextMarker: No more markers in this buffer")
 	}
 
@@ -141,6 +147,11 @@ func (this *cursor) moveForward(x int64) (int64, error) {
 		}
 	}
 
+	// Skip over markers that have no words left, so the cursor always points at the next word to
+	// check, unless it's at the end
+	for this.markerRemaining() == 0 && this.nextMarker() == nil {
+	}
+
 	this.totalChecked += a - x
 	return a - x, nil
 }
@@ -167,8 +178,8 @@ func (this *cursor) copyForward(container BitmapStorage, max int64, negated bool
 				pl = max - index
 			}
 
-			// Copy the words into the result set with the same 0 or 1 setting
-			container.addStreamOfEmptyWords(this.emptyBit(), pl)
+			// Copy the words into the result set with the same 0 or 1 setting, flipped if negated
+			container.addStreamOfEmptyWords(this.emptyBit() != negated, pl)
 
 			// Update the index to reflect the number of words copied
 			index += pl
@@ -251,6 +262,24 @@ func (this *cursor) copyForwardRemaining(container BitmapStorage) (int64, error)
 	return n, nil
 }
 
+// current returns the next word to check and the number of times it repeats: the rest of the running
+// length, or 1 for a literal word. It returns 0 and 0 if there are no more words.
+func (this *cursor) current() (uint64, int64) {
+	if n := this.emptyRemaining(); n > 0 {
+		if this.emptyBit() {
+			return ^uint64(0), n
+		}
+
+		return 0, n
+	}
+
+	if this.literalRemaining() > 0 {
+		return this.getLiteralWordAt(0), 1
+	}
+
+	return 0, 0
+}
+
 func (this *cursor) getLiteralWordAt(k int64) uint64 {
 	n := this.marker + this.literalChecked + 1 + k
 	if n >= this.bsize {
@@ -264,12 +293,14 @@ func (this *cursor) String() string {
 		this.bsize, this.marker, this.totalChecked, this.literalChecked, this.literalCount(), this.emptyChecked, this.emptyCount())
 }
 
+// end returns true if the cursor has gone through all the words of the last marker
 func (this *cursor) end() bool {
-	if this.marker+this.literalChecked+1 >= this.bsize {
-		return true
-	}
+	return this.lastMarker() && this.markerRemaining() == 0
+}
 
-	return false
+// lastMarker returns true if there are no more marker words after the current one
+func (this *cursor) lastMarker() bool {
+	return this.marker+this.literalCount()+1 >= this.bsize
 }
 
 func (this *cursor) markerWord() uint64 {
@@ -306,6 +337,7 @@ func (this *cursor) emptyRemaining() int64 {
 func (this *cursor) setLiteralCount(n int64) {
 	this.buffer[this.marker] |= NotRunningLengthPlusRunningBit
 	this.buffer[this.marker] &= (uint64(n) << uint64(RunningLengthBits+1)) | RunningLengthPlusRunningBit
+	this.updateMarkerCounts()
 }
 
 func (this *cursor) setEmptyBit(b bool) {
@@ -314,11 +346,13 @@ func (this *cursor) setEmptyBit(b bool) {
 	} else {
 		this.buffer[this.marker] &= ^uint64(1)
 	}
+	this.updateMarkerCounts()
 }
 
 func (this *cursor) setEmptyCount(n int64) {
 	this.buffer[this.marker] |= ShiftedLargestRunningLengthCount
 	this.buffer[this.marker] &= (uint64(n) << 1) | NotShiftedLargestRunningLengthCount
+	this.updateMarkerCounts()
 }
 
 // size returns the size in uncompressed words represented by this running length word
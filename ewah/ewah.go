@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"github.com/reducedb/bitmap"
 	"math"
+	"math/bits"
 )
 
 const (
@@ -52,6 +53,7 @@ type Ewah struct {
 }
 
 var _ bitmap.Bitmap = (*Ewah)(nil)
+var _ bitmap.Cardinalities = (*Ewah)(nil)
 var _ BitmapStorage = (*Ewah)(nil)
 
 func New() bitmap.Bitmap {
@@ -104,8 +106,7 @@ func (this *Ewah) Set(i int64) bitmap.Bitmap {
 
 	// Now we know dist == 0 since it can't be < 0 (can't set a bit past the current active bit)
 	if this.setCursor.literalCount() == 0 {
-		this.setCursor.setEmptyCount(this.setCursor.emptyCount() - 1)
-		this.addLiteralWord(1 << uint64(i%wordInBits))
+		this.replaceLastEmptyWord(1 << uint64(i%wordInBits))
 		return this
 	}
 
@@ -125,7 +126,15 @@ func (this *Ewah) Set(i int64) bitmap.Bitmap {
 }
 
 func (this *Ewah) Get(i int64) bool {
-	if i < 0 || i > this.sizeInBits {
+	// The marker word under the cursor may have been updated since the last Get
+	this.getCursor.quickUpdate(this.buffer, this.actualSizeInWords)
+	return this.getWithCursor(this.getCursor, i)
+}
+
+// getWithCursor checks the bit at position i, using c to walk the buffer. If the word to check is
+// before the words c has already checked, c is reset to the beginning of the buffer.
+func (this *Ewah) getWithCursor(c *cursor, i int64) bool {
+	if i < 0 || i >= this.sizeInBits {
 		return false
 	}
 
@@ -133,40 +142,45 @@ func (this *Ewah) Get(i int64) bool {
 	bitInWord := uint64(i % wordInBits)
 
 	// If the word to check is before the the words already checked then let's update the buffer
-	if wordToCheck < this.getCursor.totalChecked {
-		//fmt.Printf("ewah.go/Get: reset ---> wordToCheck = %d, bitInWord = %d, size = %d\n---> %v\n", wordToCheck, bitInWord, this.SizeInWords(), this.getCursor)
-		this.getCursor.reset(this.buffer, this.actualSizeInWords)
+	if wordToCheck < c.totalChecked {
+		//fmt.Printf("ewah.go/Get: reset ---> wordToCheck = %d, bitInWord = %d, size = %d\n---> %v\n", wordToCheck, bitInWord, this.SizeInWords(), c)
+		c.reset(this.buffer, this.actualSizeInWords)
 	}
 
 	//fmt.Printf("ewah.go/Get: ---> wordToCheck = %d, bitInWord = %d\n", wordToCheck, bitInWord)
 	//this.printDetails()
 
-	for this.getCursor.totalChecked <= wordToCheck && !this.getCursor.end() {
-		//fmt.Println("ewah.go/Get: cursor =", this.getCursor)
+	for c.totalChecked <= wordToCheck && !c.end() {
+		//fmt.Println("ewah.go/Get: cursor =", c)
 
-		emptyRemaining := this.getCursor.emptyRemaining()
+		emptyRemaining := c.emptyRemaining()
 
-		if wordToCheck < this.getCursor.totalChecked+emptyRemaining {
-			//fmt.Println("ewah.go/Get: inside empty words,", this.getCursor.emptyBit())
-			return this.getCursor.emptyBit()
+		if wordToCheck < c.totalChecked+emptyRemaining {
+			//fmt.Println("ewah.go/Get: inside empty words,", c.emptyBit())
+			return c.emptyBit()
 		}
 
-		this.getCursor.moveForward(emptyRemaining)
+		c.moveForward(emptyRemaining)
 
-		//fmt.Printf("ewah.go/Get: after move forward %d empty words, cursor = %v\n", emptyRemaining, this.getCursor)
+		//fmt.Printf("ewah.go/Get: after move forward %d empty words, cursor = %v\n", emptyRemaining, c)
 
-		literalRemaining := this.getCursor.literalRemaining()
+		// If the marker had no literal words, we are now at the empty words of the next marker
+		if c.emptyRemaining() > 0 {
+			continue
+		}
+
+		literalRemaining := c.literalRemaining()
 
-		if wordToCheck < this.getCursor.totalChecked+literalRemaining {
-			//i := this.getCursor.marker + (wordToCheck - this.getCursor.totalChecked) + 1
+		if wordToCheck < c.totalChecked+literalRemaining {
+			//i := c.marker + (wordToCheck - c.totalChecked) + 1
 			//fmt.Printf("%4d: %20d %064b\n", i, uint64(this.buffer[i]), uint64(this.buffer[i]))
-			b := this.buffer[this.getCursor.marker+(wordToCheck-this.getCursor.totalChecked)+1]&(uint64(1)<<bitInWord) != 0
+			b := this.buffer[c.marker+(wordToCheck-c.totalChecked)+1]&(uint64(1)<<bitInWord) != 0
 			//fmt.Printf("ewah.go/Get: inside literal words and it's %t\n", b)
 			return b
 		}
 
-		this.getCursor.moveForward(literalRemaining)
-		//fmt.Printf("ewah.go/Get: after move forward %d literal words, cursor = %v\n", literalRemaining, this.getCursor)
+		c.moveForward(literalRemaining)
+		//fmt.Printf("ewah.go/Get: after move forward %d literal words, cursor = %v\n", literalRemaining, c)
 	}
 
 	return false
@@ -222,12 +236,13 @@ func (this *Ewah) Swap(other *Ewah) bitmap.Bitmap {
 	this.sizeInBits, other.sizeInBits = other.sizeInBits, this.sizeInBits
 
 	s1, s2 := this.setCursor.marker, other.setCursor.marker
-	g1, g2 := this.getCursor.marker, other.getCursor.marker
 
+	// The get cursors start over since the number of words checked is only valid for the buffer
+	// they were walking
 	this.setCursor.resetMarker(this.buffer, this.actualSizeInWords, s2)
-	this.getCursor.resetMarker(this.buffer, this.actualSizeInWords, g2)
+	this.getCursor.reset(this.buffer, this.actualSizeInWords)
 	other.setCursor.resetMarker(other.buffer, other.actualSizeInWords, s1)
-	other.getCursor.resetMarker(other.buffer, other.actualSizeInWords, g1)
+	other.getCursor.reset(other.buffer, other.actualSizeInWords)
 
 	return this
 }
@@ -240,22 +255,24 @@ func (this *Ewah) Clone() bitmap.Bitmap {
 	c.sizeInBits = this.sizeInBits
 
 	c.setCursor.resetMarker(c.buffer, c.actualSizeInWords, this.setCursor.marker)
-	c.getCursor.resetMarker(c.buffer, c.actualSizeInWords, this.getCursor.marker)
-	c.getCursor.totalChecked = this.getCursor.totalChecked
+	c.getCursor.reset(c.buffer, c.actualSizeInWords)
 
 	return c
 }
 
 func (this *Ewah) Copy(other bitmap.Bitmap) bitmap.Bitmap {
-	o := other.(*Ewah)
+	o, ok := toEwah(other)
+	if !ok {
+		return nil
+	}
+
 	this.buffer = make([]uint64, o.SizeInWords())
 	copy(this.buffer, o.buffer)
 	this.actualSizeInWords = o.SizeInWords()
 	this.sizeInBits = o.Size()
 
 	this.setCursor.resetMarker(this.buffer, this.actualSizeInWords, o.setCursor.marker)
-	this.getCursor.resetMarker(this.buffer, this.actualSizeInWords, o.getCursor.marker)
-	this.getCursor.totalChecked = o.getCursor.totalChecked
+	this.getCursor.reset(this.buffer, this.actualSizeInWords)
 
 	return this
 }
@@ -265,7 +282,11 @@ func (this *Ewah) Equal(other bitmap.Bitmap) bool {
 		return false
 	}
 
-	o := other.(*Ewah)
+	o, ok := toEwah(other)
+	if !ok {
+		return false
+	}
+
 	if this.Size() != o.Size() {
 		return false
 	}
@@ -280,6 +301,21 @@ func (this *Ewah) Equal(other bitmap.Bitmap) bool {
 	return true
 }
 
+// SameBits returns true if other has the same bits set as this bitmap, whatever its size or
+// implementation. Two EWAH bitmaps are compared without uncompressing them.
+func (this *Ewah) SameBits(other bitmap.Bitmap) bool {
+	if o, ok := toEwah(other); ok {
+		return this.xorCardinality(o) == 0
+	}
+
+	return bitmap.SameBits(this, other)
+}
+
+// Hash returns a hash of the positions of the bits set, see bitmap.Hash.
+func (this *Ewah) Hash() uint64 {
+	return bitmap.Hash(this)
+}
+
 func (this *Ewah) Cardinality() int64 {
 	n := int64(0)
 	c := newCursor(this.buffer, this.actualSizeInWords)
@@ -303,8 +339,151 @@ func (this *Ewah) Cardinality() int64 {
 	return n
 }
 
+// Stats walks the marker words and reports how the bitmap is compressed.
+func (this *Ewah) Stats() bitmap.Stats {
+	stats := bitmap.Stats{
+		SizeInBits:  this.Size(),
+		SizeInWords: this.SizeInWords(),
+		SizeInBytes: this.SizeInBytes(),
+		RunLengths:  make([]int64, RunningLengthBits),
+	}
+
+	c := newCursor(this.buffer, this.actualSizeInWords)
+
+	for {
+		stats.MarkerWords += 1
+		stats.LiteralWords += c.literalCount()
+
+		if n := c.emptyCount(); n > 0 {
+			stats.RunLengths[bits.Len64(uint64(n))-1] += 1
+
+			if c.emptyBit() {
+				stats.Cardinality += wordInBits * n
+			}
+		}
+
+		for j := int64(0); j < c.literalCount(); j++ {
+			stats.Cardinality += int64(popcount_3(c.getLiteralWordAt(j)))
+		}
+
+		if c.lastMarker() || c.nextMarker() != nil {
+			break
+		}
+	}
+
+	if stats.SizeInWords > 0 {
+		stats.CompressionRatio = float64(stats.UncompressedWords()) / float64(stats.SizeInWords)
+	}
+
+	return stats
+}
+
+// Validate walks the marker words and checks that the buffer is a well-formed and canonical encoding
+// of Size() bits: the literal words of every marker fit in the buffer, the markers add up to Size(),
+// no literal word is all 0's or all 1's, no adjacent markers could have been merged, and no bits are
+// set past Size(). It returns an error describing the first problem found.
+func (this *Ewah) Validate() error {
+	if this.actualSizeInWords < 1 || this.actualSizeInWords > int64(len(this.buffer)) {
+		return fmt.Errorf("ewah/Validate: %d words used, but the buffer only has %d", this.actualSizeInWords, len(this.buffer))
+	}
+
+	if this.sizeInBits < 0 {
+		return fmt.Errorf("ewah/Validate: negative size %d", this.sizeInBits)
+	}
+
+	words := int64(0)
+	c := newCursor(this.buffer, this.actualSizeInWords)
+
+	// The previous marker, to check whether it should have been merged with the current one
+	prev, prevLiterals, prevEmpty, prevBit := int64(-1), int64(0), int64(0), false
+
+	for {
+		if c.marker+c.literalCount() >= this.actualSizeInWords {
+			return fmt.Errorf("ewah/Validate: marker %d has %d literal words, past the %d words used", c.marker, c.literalCount(), this.actualSizeInWords)
+		}
+
+		if c.size() == 0 && !c.lastMarker() {
+			return fmt.Errorf("ewah/Validate: marker %d is empty", c.marker)
+		}
+
+		if prev >= 0 {
+			if c.emptyCount() == 0 && uint64(prevLiterals+c.literalCount()) <= LargestLiteralCount {
+				return fmt.Errorf("ewah/Validate: marker %d has no running length, its literal words belong to marker %d", c.marker, prev)
+			}
+
+			if prevLiterals == 0 && prevBit == c.emptyBit() && uint64(prevEmpty+c.emptyCount()) <= LargestRunningLengthCount {
+				return fmt.Errorf("ewah/Validate: the running lengths of markers %d and %d should be merged", prev, c.marker)
+			}
+		}
+
+		for j := int64(0); j < c.literalCount(); j++ {
+			if w := c.getLiteralWordAt(j); w == 0 || w == ^uint64(0) {
+				return fmt.Errorf("ewah/Validate: literal word %d of marker %d should be a running length", c.marker+j+1, c.marker)
+			}
+		}
+
+		words += c.size()
+		prev, prevLiterals, prevEmpty, prevBit = c.marker, c.literalCount(), c.emptyCount(), c.emptyBit()
+
+		if c.lastMarker() || c.nextMarker() != nil {
+			break
+		}
+	}
+
+	if c.marker+c.literalCount()+1 != this.actualSizeInWords {
+		return fmt.Errorf("ewah/Validate: markers end at word %d, but %d words are used", c.marker+c.literalCount()+1, this.actualSizeInWords)
+	}
+
+	if expected := (this.sizeInBits + wordInBits - 1) / wordInBits; words != expected {
+		return fmt.Errorf("ewah/Validate: markers add up to %d words, but a size of %d bits needs %d", words, this.sizeInBits, expected)
+	}
+
+	if lastBits := this.sizeInBits % wordInBits; lastBits != 0 {
+		if c.literalCount() > 0 && this.buffer[this.actualSizeInWords-1]>>uint64(lastBits) != 0 {
+			return fmt.Errorf("ewah/Validate: the last literal word has bits set past the size of %d bits", this.sizeInBits)
+		}
+
+		if c.literalCount() == 0 && c.emptyBit() && c.emptyCount() > 0 {
+			return fmt.Errorf("ewah/Validate: the last running length of 1's goes past the size of %d bits", this.sizeInBits)
+		}
+	}
+
+	return nil
+}
+
+// Compact rewrites the buffer in canonical form: adjacent running lengths are merged, literal words
+// of all 0's or all 1's become running lengths, and the buffer is trimmed to the words actually used.
+// Two compacted bitmaps with the same bits and size have the same buffer.
+func (this *Ewah) Compact() bitmap.Bitmap {
+	tmp := New().(*Ewah)
+	c := newCursor(this.buffer, this.actualSizeInWords)
+
+	for {
+		tmp.addStreamOfEmptyWords(c.emptyBit(), c.emptyCount())
+
+		for j := int64(0); j < c.literalCount(); j++ {
+			tmp.add(c.getLiteralWordAt(j))
+		}
+
+		if c.lastMarker() || c.nextMarker() != nil {
+			break
+		}
+	}
+
+	this.buffer = make([]uint64, tmp.actualSizeInWords)
+	copy(this.buffer, tmp.buffer)
+	this.actualSizeInWords = tmp.actualSizeInWords
+
+	this.setCursor.resetMarker(this.buffer, this.actualSizeInWords, tmp.setCursor.marker)
+	this.getCursor.reset(this.buffer, this.actualSizeInWords)
+
+	return this
+}
+
+// PrintStats prints the bitmap statistics to stdout. If details is true, the buffer words are
+// printed as well.
 func (this *Ewah) PrintStats(details bool) {
-	fmt.Printf("actualSizeInWords = %d, actualSizeInBits = %d, cardinality = %d\n", this.SizeInWords(), this.Size(), this.Cardinality())
+	fmt.Println(this.Stats())
 
 	if details {
 		this.printDetails()
@@ -339,7 +518,7 @@ func (this *Ewah) addSignificantBits(newdata uint64, bitsthatmatter int64) {
 	this.sizeInBits += bitsthatmatter
 	if newdata == 0 {
 		this.addEmptyWord(false)
-	} else if newdata == ^uint64(1) {
+	} else if newdata == ^uint64(0) {
 		this.addEmptyWord(true)
 	} else {
 		this.addLiteralWord(newdata)
@@ -382,6 +561,59 @@ func (this *Ewah) addLiteralWord(newdata uint64) {
 	this.pushback(newdata)
 }
 
+// replaceLastEmptyWord replaces the last word of the bitmap, which must be the last word of the running
+// length of the last marker, with a literal word.
+func (this *Ewah) replaceLastEmptyWord(newdata uint64) {
+	this.removeLastWord()
+	this.addLiteralWord(newdata)
+}
+
+// removeLastWord removes the last uncompressed word from the buffer and returns it. If that leaves the
+// last marker with no words at all, the marker is dropped so the next words go to the previous marker.
+// The size in bits is not changed.
+func (this *Ewah) removeLastWord() uint64 {
+	w := uint64(0)
+
+	if n := this.setCursor.literalCount(); n > 0 {
+		w = this.buffer[this.actualSizeInWords-1]
+		this.buffer[this.actualSizeInWords-1] = 0
+		this.actualSizeInWords -= 1
+		this.setCursor.setLiteralCount(n - 1)
+	} else if n := this.setCursor.emptyCount(); n > 0 {
+		if this.setCursor.emptyBit() {
+			w = ^uint64(0)
+		}
+
+		this.setCursor.setEmptyCount(n - 1)
+	}
+
+	if m := this.setCursor.marker; m > 0 && this.setCursor.size() == 0 {
+		this.buffer[m] = 0
+		this.actualSizeInWords -= 1
+		this.setCursor.resetMarker(this.buffer, this.actualSizeInWords, this.previousMarker(m))
+	}
+
+	this.getCursor.reset(this.buffer, this.actualSizeInWords)
+
+	return w
+}
+
+// previousMarker returns the position of the marker word before the one at position m
+func (this *Ewah) previousMarker(m int64) int64 {
+	c := newCursor(this.buffer, this.actualSizeInWords)
+	prev := int64(0)
+
+	for c.marker < m {
+		prev = c.marker
+
+		if c.nextMarker() != nil {
+			break
+		}
+	}
+
+	return prev
+}
+
 // addStreamOfLiteralWords adds several literal words at a time, might be faster
 func (this *Ewah) addStreamOfLiteralWords(data []uint64, start, number int32) {
 	leftOverNumber := int64(number)
@@ -609,6 +841,21 @@ func (this *Ewah) setSizeInBitsWithDefault(size int64, defaultValue bool) bool {
 
 }
 
+// toEwah returns the *Ewah behind a bitmap, unwrapping frozen bitmaps and evaluating lazy expressions.
+// It returns false if the bitmap is not an EWAH bitmap.
+func toEwah(b bitmap.Bitmap) (*Ewah, bool) {
+	switch bm := b.(type) {
+	case *Ewah:
+		return bm, true
+	case *Frozen:
+		return bm.bm, true
+	case *bitmap.Expr:
+		return toEwah(bm.Eval())
+	}
+
+	return nil, false
+}
+
 func (this *Ewah) toArray() []int {
 	return nil
 }
@@ -623,6 +870,7 @@ func (this *Ewah) reserve(size int32) bitmap.Bitmap {
 		this.buffer = make([]uint64, size)
 		copy(this.buffer, oldBuffer)
 		this.setCursor.reset(this.buffer, this.actualSizeInWords)
+		this.getCursor.reset(this.buffer, this.actualSizeInWords)
 	}
 
 	return this
@@ -9,6 +9,10 @@ package ewah
 import (
 	"fmt"
 	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitmaptest"
+	"github.com/reducedb/bitmap/bitset"
+	"iter"
+	"math/bits"
 	"math/rand"
 	"testing"
 )
@@ -334,6 +338,201 @@ func TestXor(t *testing.T) {
 	}
 }
 
+func TestFreeze(t *testing.T) {
+	bm2 := New().(*Ewah)
+	bm2.Set(10)
+	bm2.Set(100)
+	bm2.Set(15000)
+
+	f := bm2.Freeze()
+
+	nums2 := []int64{15000, 100, 10}
+	for i := range nums2 {
+		if !f.Get(nums2[i]) {
+			t.Fatalf("Get(%d) failed, should be set\n", nums2[i])
+		}
+	}
+
+	if f.Get(11) {
+		t.Fatalf("Get(%d) failed, should NOT be set\n", 11)
+	}
+
+	if c := f.(*Frozen).bm.getCursor; c.totalChecked != 0 {
+		t.Fatalf("Get on frozen bitmap moved the get cursor to %d", c.totalChecked)
+	}
+
+	if f.Cardinality() != 3 || !f.Equal(bm2) || !bm2.Equal(f) {
+		t.Fatal("Frozen bitmap does not match the original")
+	}
+
+	bm3 := New().(*Ewah)
+	bm3.Set(100)
+
+	if c := bm3.And(f).Cardinality(); c != 1 {
+		t.Fatalf("And with frozen bitmap: cardinality %d != 1", c)
+	}
+
+	if c := f.Or(bm3.Freeze()).Cardinality(); c != 3 {
+		t.Fatalf("Or of frozen bitmaps: cardinality %d != 3", c)
+	}
+
+	if c := f.Clone().Set(20000).Cardinality(); c != 4 {
+		t.Fatalf("Clone of frozen bitmap: cardinality %d != 4", c)
+	}
+
+	mutators := map[string]func(){
+		"Set":   func() { f.Set(20000) },
+		"Reset": func() { f.Reset() },
+		"Copy":  func() { f.Copy(bm3) },
+		"Not":   func() { f.Not() },
+	}
+
+	for name, fn := range mutators {
+		func() {
+			defer func() {
+				if r := recover(); r != bitmap.ErrFrozen {
+					t.Fatalf("%s on frozen bitmap: expected panic with ErrFrozen, got %v", name, r)
+				}
+			}()
+			fn()
+		}()
+	}
+
+	if bm2.Cardinality() != 3 {
+		t.Fatal("Frozen bitmap was modified")
+	}
+
+	// Changes to the original bitmap must not show through the frozen copy
+	bm2.Set(20000)
+	bm2.Not()
+	if f.Cardinality() != 3 || !f.Get(15000) || f.Get(20000) || f.Size() != 15001 {
+		t.Fatal("Frozen bitmap changed with the original")
+	}
+
+	bm2.Copy(bm3)
+	bm2.Reset()
+	if f.Cardinality() != 3 || !f.Get(10) {
+		t.Fatal("Frozen bitmap changed with the original")
+	}
+
+	// Writing to the words of a frozen bitmap must not change it
+	g := New().Set(3).Set(40).(*Ewah).Freeze()
+	words := g.(*Frozen).Words()
+	words[0] = 1 << 5
+	if !g.Get(3) || !g.Get(40) || g.Get(5) || g.Cardinality() != 2 {
+		t.Fatal("Frozen bitmap changed through its words")
+	}
+}
+
+func TestFrozenGet(t *testing.T) {
+	bm := New().(*Ewah)
+	for i := int64(0); i < 100000; i += 1 + i%97 {
+		bm.Set(i)
+	}
+	for i := int64(200000); i < 200640; i++ {
+		bm.Set(i)
+	}
+	bm.Set(1000000)
+
+	f := bm.Freeze()
+	for i := bm.Size() + 100; i >= -1; i-- {
+		if f.Get(i) != bm.Get(i) {
+			t.Fatalf("Get(%d) on frozen bitmap = %t, expected %t", i, f.Get(i), bm.Get(i))
+		}
+	}
+
+	if New().(*Ewah).Freeze().Get(0) {
+		t.Fatal("Get(0) on frozen empty bitmap should be false")
+	}
+}
+
+func TestStats(t *testing.T) {
+	bm2 := New().(*Ewah)
+
+	// 10 literal words followed by a run of 1000 empty words and then one more literal word
+	for i := int64(0); i < 640; i += 7 {
+		bm2.Set(i)
+	}
+	bm2.Set(64640)
+
+	s := bm2.Stats()
+
+	if s.SizeInBits != bm2.Size() || s.SizeInWords != bm2.SizeInWords() || s.SizeInBytes != bm2.SizeInBytes() {
+		t.Fatalf("Sizes do not match the bitmap: %v", s)
+	}
+
+	if s.Cardinality != bm2.Cardinality() {
+		t.Fatalf("Cardinality %d != %d", s.Cardinality, bm2.Cardinality())
+	}
+
+	if s.MarkerWords != 2 || s.LiteralWords != 11 || s.MarkerWords+s.LiteralWords != s.SizeInWords {
+		t.Fatalf("Expected 2 marker words and 11 literal words: %v", s)
+	}
+
+	// A run of 1000 words falls in the 512-1023 bucket
+	if s.RunLengths[9] != 1 {
+		t.Fatalf("Expected one run of 512-1023 words: %v", s.RunLengths)
+	}
+
+	if s.CompressionRatio <= 1 {
+		t.Fatalf("Compression ratio %.2f should be greater than 1", s.CompressionRatio)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	bs := bitset.New().(*bitset.Bitset)
+
+	// A literal word, a run of 1's, a run of 0's and a partial last word
+	nums2 := []int64{3, 17}
+	for i := int64(64); i < 64*5; i++ {
+		nums2 = append(nums2, i)
+	}
+	nums2 = append(nums2, 64*500+1, 64*500+9)
+
+	for _, v := range nums2 {
+		bs.Set(v)
+	}
+
+	e := FromBitset(bs).(*Ewah)
+
+	if e.Size() != bs.Size() || e.Cardinality() != int64(len(nums2)) {
+		t.Fatalf("FromBitset: size %d != %d or cardinality %d != %d", e.Size(), bs.Size(), e.Cardinality(), len(nums2))
+	}
+
+	for _, v := range nums2 {
+		if !e.Get(v) {
+			t.Fatalf("FromBitset: Get(%d) failed, should be set\n", v)
+		}
+	}
+
+	// The 4 words of 1's should have been compressed into a single running length
+	if s := e.Stats(); s.LiteralWords != 2 {
+		t.Fatalf("FromBitset: expected 2 literal words: %v", s)
+	}
+
+	if e.Set(64*500+20) == nil || !e.Get(64*500+20) {
+		t.Fatal("FromBitset: problem setting a bit after conversion")
+	}
+
+	bs2 := bitset.FromEwah(e)
+
+	if bs2.Size() != e.Size() || bs2.Cardinality() != e.Cardinality() {
+		t.Fatalf("FromEwah: size %d != %d or cardinality %d != %d", bs2.Size(), e.Size(), bs2.Cardinality(), e.Cardinality())
+	}
+
+	for _, v := range append(nums2, 64*500+20) {
+		if !bs2.Get(v) {
+			t.Fatalf("FromEwah: Get(%d) failed, should be set\n", v)
+		}
+	}
+
+	e2 := bitmap.Convert(bs2, New)
+
+	if e2.Cardinality() != e.Cardinality() || !e2.Equal(e) {
+		t.Fatal("Convert: bitmaps are not equal")
+	}
+}
+
 func BenchmarkGet(b *testing.B) {
 	//fmt.Printf("BenchmarkSetAndGet %d bits\n", b.N)
 	failed := 0
@@ -397,87 +596,745 @@ func BenchmarkXor(b *testing.B) {
 	}
 }
 
-// f is the function to call, like And, Or, Xor, AndNot
-// b1 is the number of bits for the first bitmap
-// b2 is the number of bits for the second bitmap
-// s1 is the sparsity of the first bitmap
-// s2 is the sparsity of the second bitmap
-func benchmarkDifferentCombinations(b *testing.B, op string, b1, b2 int, s1, s2 int) {
-	m1 := New().(*Ewah)
-	m2 := New().(*Ewah)
+func BenchmarkOps(b *testing.B) {
+	bitmaptest.Benchmark(b, New)
+}
+
+func TestConformance(t *testing.T) {
+	bitmaptest.Run(t, New, bitmaptest.Options{Ascending: true})
+}
+
+// maxFuzzBits caps the size of the bitmaps built from fuzzer data, so each input is checked quickly
+const maxFuzzBits = 1 << 16
+
+// fuzzBitmap builds a bitmap and its uncompressed words from fuzzer data. Each byte either sets a
+// bit after a small gap, sets a run of bits long enough to fill whole words with 1's, or sets a bit
+// after a gap of whole words of 0's.
+func fuzzBitmap(data []byte) (*Ewah, []uint64) {
+	bm := New().(*Ewah)
+	words := make([]uint64, 0, maxFuzzBits/wordInBits)
+
+	set := func(i int64) bool {
+		if i >= maxFuzzBits {
+			return false
+		}
+
+		for int64(len(words)) <= i/wordInBits {
+			words = append(words, 0)
+		}
+
+		words[i/wordInBits] |= 1 << uint64(i%wordInBits)
+		bm.Set(i)
+		return true
+	}
 
 	bit := int64(0)
-	rand.Seed(int64(c1))
-	for i := 0; i < b1; i++ {
-		bit += int64(rand.Intn(s1) + 1)
-		m1.Set(bit)
+	for _, v := range data {
+		switch v >> 6 {
+		case 0:
+			bit += int64(v)
+			if !set(bit) {
+				return bm, words
+			}
+		case 1:
+			for n := int64(v&0x3f+1) * 8; n > 0; n-- {
+				if !set(bit) {
+					return bm, words
+				}
+				bit += 1
+			}
+		default:
+			bit += int64(v&0x7f) * wordInBits
+			if !set(bit) {
+				return bm, words
+			}
+		}
+
+		bit += 1
 	}
 
-	bit = 0
-	rand.Seed(int64(c2))
-	for i := 0; i < b2; i++ {
-		bit += int64(rand.Intn(s1) + 1)
-		m2.Set(bit)
+	return bm, words
+}
+
+// fuzzModel returns the words of a bitmap of size bits, where each word is f of the words of the
+// operands at the same position. Operands shorter than size are padded with 0's.
+func fuzzModel(size int64, f func(w []uint64) uint64, operands ...[]uint64) []uint64 {
+	words := make([]uint64, (size+wordInBits-1)/wordInBits)
+	w := make([]uint64, len(operands))
+
+	for i := range words {
+		for k, o := range operands {
+			w[k] = 0
+			if i < len(o) {
+				w[k] = o[i]
+			}
+		}
+
+		words[i] = f(w)
 	}
 
-	var f func(...bitmap.Bitmap) bitmap.Bitmap
-	switch op {
-	case "and":
-		f = m1.And
-	case "or":
-		f = m1.Or
-	case "andnot":
-		f = m1.AndNot
-	case "xor":
-		f = m1.Xor
-	default:
-		return
+	if size%wordInBits != 0 {
+		words[len(words)-1] &= 1<<uint64(size%wordInBits) - 1
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		if f(m2) == nil {
-			b.Fatal("Problem with %s benchmark at i =", op, i)
+	return words
+}
+
+// fuzzCheck checks bm against the words of the model, word by word
+func fuzzCheck(t *testing.T, what string, bm *Ewah, words []uint64, size int64) {
+	if err := bm.Validate(); err != nil {
+		bm.PrintStats(true)
+		t.Fatalf("%s: %v", what, err)
+	}
+
+	if bm.Size() != size {
+		t.Fatalf("%s: Size() = %d, expected %d", what, bm.Size(), size)
+	}
+
+	words = fuzzModel(size, func(w []uint64) uint64 { return w[0] }, words)
+	got := bm.Words()
+	if len(got) != len(words) {
+		t.Fatalf("%s: Words() returned %d words, expected %d", what, len(got), len(words))
+	}
+
+	card := int64(0)
+	for i, w := range words {
+		if got[i] != w {
+			t.Fatalf("%s: word %d = %064b, expected %064b", what, i, got[i], w)
+		}
+
+		// Get walks the compressed words with the get cursor, so check it at both ends of each word
+		for _, k := range []int64{0, wordInBits - 1} {
+			if j := int64(i)*wordInBits + k; bm.Get(j) != (w&(1<<uint64(k)) != 0) {
+				t.Fatalf("%s: Get(%d) = %t", what, j, bm.Get(j))
+			}
 		}
+
+		card += int64(bits.OnesCount64(w))
 	}
+
+	if bm.Get(size) || bm.Get(size+wordInBits) {
+		t.Fatalf("%s: Get() returned true past the end of the bitmap", what)
+	}
+
+	if c := bm.Cardinality(); c != card {
+		t.Fatalf("%s: Cardinality() = %d, expected %d", what, c, card)
+	}
+
+	checkIterators(t, what, bm, words)
 }
 
-func testGenerateData(t *testing.T) {
-	is := []int{100, 10000, 1000000}
-	js := []int{100, 10000, 1000000}
-	ks := []int{3, 30, 300, 3000, 30000}
-	ls := []int{3, 30, 300, 3000, 30000}
+// checkIterators checks that All returns the bits of words in ascending order, and Runs the maximal
+// runs of them
+func checkIterators(t *testing.T, what string, bm *Ewah, words []uint64) {
+	all := make([]uint64, len(words))
+	prev := int64(-1)
+	for i := range bm.All() {
+		if i <= prev || i >= int64(len(words))*wordInBits {
+			t.Fatalf("%s: All() returned %d after %d", what, i, prev)
+		}
 
-	m1 := New().(*Ewah)
-	m2 := New().(*Ewah)
+		all[i/wordInBits] |= 1 << uint64(i%wordInBits)
+		prev = i
+	}
 
-	for i := range is {
-		for j := range js {
-			for k := range ks {
-				for l := range ls {
-					bit := int64(0)
-					rand.Seed(int64(c1))
-					for a := 0; a < is[i]; a++ {
-						bit += int64(rand.Intn(ks[k]) + 1)
-						m1.Set(bit)
-					}
+	for i, w := range words {
+		if all[i] != w {
+			t.Fatalf("%s: All() returned %064b in word %d, expected %064b", what, all[i], i, w)
+		}
+	}
+
+	// Backward returns the same positions in descending order, with and without a frozen marker index
+	for _, seq := range []iter.Seq[int64]{bm.Backward(), bm.Freeze().(*Frozen).Backward()} {
+		back := make([]uint64, len(words))
+		prev = int64(len(words)) * wordInBits
+		for i := range seq {
+			if i >= prev || i < 0 {
+				t.Fatalf("%s: Backward() returned %d after %d", what, i, prev)
+			}
+
+			back[i/wordInBits] |= 1 << uint64(i%wordInBits)
+			prev = i
+		}
+
+		for i, w := range words {
+			if back[i] != w {
+				t.Fatalf("%s: Backward() returned %064b in word %d, expected %064b", what, back[i], i, w)
+			}
+		}
+	}
+
+	// Runs that are in order, don't touch and cover exactly the bits set must be the maximal runs
+	runs := make([]uint64, len(words))
+	prev = -1
+	for start, length := range bm.Runs() {
+		if start <= prev || length <= 0 || start+length > int64(len(words))*wordInBits {
+			t.Fatalf("%s: Runs() returned (%d, %d) after a run ending at %d", what, start, length, prev)
+		}
+
+		for i := start; i < start+length; i++ {
+			runs[i/wordInBits] |= 1 << uint64(i%wordInBits)
+		}
+
+		prev = start + length
+	}
+
+	for i, w := range words {
+		if runs[i] != w {
+			t.Fatalf("%s: Runs() covered %064b in word %d, expected %064b", what, runs[i], i, w)
+		}
+	}
+}
+
+func FuzzSetNot(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 63, 64, 65})
+	f.Add([]byte{0x47, 0x80, 0x41, 0xff, 3})
+	f.Add([]byte{0x4f, 0x4f, 0x4f})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bm, words := fuzzBitmap(data)
+		size := bm.Size()
+
+		fuzzCheck(t, "Set", bm, words, size)
+
+		flipped := fuzzModel(size, func(w []uint64) uint64 { return ^w[0] }, words)
+
+		fuzzCheck(t, "Not", bm.Not().(*Ewah), flipped, size)
+		fuzzCheck(t, "Not twice", bm.Not().(*Ewah), words, size)
+
+		// Setting the next bit after a Not may have to split a running length in the last word
+		bm.Not().Set(size)
+		flipped = fuzzModel(size+1, func(w []uint64) uint64 { return w[0] }, flipped)
+		flipped[size/wordInBits] |= 1 << uint64(size%wordInBits)
+		fuzzCheck(t, "Set after Not", bm, flipped, size+1)
+	})
+}
+
+func FuzzBinaryOps(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 63, 64, 65}, []byte{1, 0x47, 0x80}, byte(0))
+	f.Add([]byte{0x80, 0x7f, 0x4f}, []byte{0x4f, 0x4f, 0x4f}, byte(1))
+	f.Add([]byte{0xc3, 0x4f}, []byte{0xc3, 0x4f}, byte(2))
+	f.Add([]byte{0xc3, 0x4f}, []byte{0xc3, 0x4f}, byte(3))
+
+	f.Fuzz(func(t *testing.T, a, b []byte, op byte) {
+		bm1, words1 := fuzzBitmap(a)
+		bm2, words2 := fuzzBitmap(b)
+
+		size := bm1.Size()
+		if bm2.Size() > size {
+			size = bm2.Size()
+		}
+
+		var ans bitmap.Bitmap
+		var f func(w []uint64) uint64
+
+		switch op % 4 {
+		case 0:
+			ans, f = bm1.And(bm2), func(w []uint64) uint64 { return w[0] & w[1] }
+		case 1:
+			ans, f = bm1.Or(bm2), func(w []uint64) uint64 { return w[0] | w[1] }
+		case 2:
+			ans, f = bm1.AndNot(bm2), func(w []uint64) uint64 { return w[0] &^ w[1] }
+		case 3:
+			ans, f = bm1.Xor(bm2), func(w []uint64) uint64 { return w[0] ^ w[1] }
+		}
+
+		fuzzCheck(t, fmt.Sprintf("op %d", op%4), ans.(*Ewah), fuzzModel(size, f, words1, words2), size)
+		fuzzCheck(t, "operand", bm1, words1, bm1.Size())
+	})
+}
+
+func TestValidate(t *testing.T) {
+	bm := New().(*Ewah)
+	for _, i := range []int64{10, 64, 65, 1000, 5000, 5001} {
+		bm.Set(i)
+	}
+
+	if err := bm.Validate(); err != nil {
+		t.Fatalf("Valid bitmap failed validation: %v", err)
+	}
+
+	// A literal word of 0's should have been a running length
+	bm2 := bm.Clone().(*Ewah)
+	bm2.buffer[1] = 0
+	if bm2.Validate() == nil {
+		t.Fatal("Literal word of 0's passed validation")
+	}
 
-					bit = 0
-					rand.Seed(int64(c2))
-					for b := 0; b < js[j]; b++ {
-						bit += int64(rand.Intn(ls[l]) + 1)
-						m2.Set(bit)
+	// Literal words running past the end of the buffer
+	bm2 = bm.Clone().(*Ewah)
+	bm2.buffer[0] += 10 << uint64(1+RunningLengthBits)
+	if bm2.Validate() == nil {
+		t.Fatal("Literal count past the end of the buffer passed validation")
+	}
+
+	// Size that doesn't match the markers
+	bm2 = bm.Clone().(*Ewah)
+	bm2.sizeInBits += wordInBits
+	if bm2.Validate() == nil {
+		t.Fatal("Wrong size passed validation")
+	}
+
+	// Two running lengths of 0's that should have been one
+	bm2 = New().(*Ewah)
+	bm2.addStreamOfEmptyWords(false, 3)
+	bm2.pushback(0)
+	bm2.setCursor.resetMarker(bm2.buffer, bm2.actualSizeInWords, bm2.actualSizeInWords-1)
+	bm2.setCursor.setEmptyCount(2)
+	bm2.sizeInBits += 2 * wordInBits
+	if bm2.Validate() == nil {
+		t.Fatal("Running lengths that should have been merged passed validation")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	// Build the same bits in a non-canonical way: split running lengths, and literal words that
+	// should have been running lengths
+	bm := New().(*Ewah)
+	bm.addStreamOfEmptyWords(false, 3)
+	bm.pushback(0)
+	bm.setCursor.resetMarker(bm.buffer, bm.actualSizeInWords, bm.actualSizeInWords-1)
+	bm.setCursor.setEmptyCount(2)
+	bm.addStreamOfLiteralWords([]uint64{^uint64(0), 0, 1 << 3}, 0, 3)
+	bm.sizeInBits = 7*wordInBits + 4
+
+	if bm.Validate() == nil {
+		t.Fatal("Non-canonical bitmap passed validation")
+	}
+
+	bm2 := New().(*Ewah)
+	bm2.Set(5 * 64)
+	for i := int64(5*64 + 1); i < 6*64; i++ {
+		bm2.Set(i)
+	}
+	bm2.Set(7*64 + 3)
+
+	if bm.Equal(bm2) {
+		t.Fatal("Bitmaps shouldn't be equal before compacting")
+	}
+
+	if bm.Compact() == nil {
+		t.Fatal("Compact returned nil")
+	}
+
+	if err := bm.Validate(); err != nil {
+		t.Fatalf("Compacted bitmap failed validation: %v", err)
+	}
+
+	if !bm.Equal(bm2) {
+		bm.PrintStats(true)
+		bm2.PrintStats(true)
+		t.Fatal("Compacted bitmap is not equal to the canonical one")
+	}
+
+	if cap(bm.buffer) != int(bm.SizeInWords()) {
+		t.Fatalf("Buffer capacity %d != %d words used", cap(bm.buffer), bm.SizeInWords())
+	}
+
+	// The bitmap should still work after compacting
+	bm.Set(10000)
+	bm2.Set(10000)
+
+	if !bm.Equal(bm2) || !bm.Get(10000) || !bm.Get(7*64+3) || bm.Get(7*64+2) {
+		t.Fatal("Compacted bitmap is broken after Set")
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	acl := New()
+	for _, i := range []int64{3, 64, 1000, 100000} {
+		acl.Set(i)
+	}
+
+	doc := bitset.New()
+	doc.Set(100000)
+	doc.Set(5)
+
+	if !acl.Intersects(doc) || acl.IsDisjoint(doc) {
+		t.Fatal("Bitmaps share bit 100000 and should intersect")
+	}
+
+	if acl.IsSubsetOf(doc) || acl.IsSupersetOf(doc) {
+		t.Fatal("Neither bitmap should be a subset of the other")
+	}
+
+	doc.Set(3).Set(64).Set(1000)
+	if !acl.IsSubsetOf(doc) || !doc.IsSupersetOf(acl) {
+		t.Fatal("Bitmap should be a subset of the bitset with more bits")
+	}
+
+	// The running length of 0's after bit 1000 covers the empty words of the other bitmap
+	other := New().Set(2000).Set(200000)
+	if acl.Intersects(other) || !acl.IsDisjoint(other.(*Ewah).Freeze()) {
+		t.Fatal("Bitmaps with no bits in common should be disjoint")
+	}
+}
+
+func TestShift(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		p := bitmaptest.Positions(r)
+
+		bm := New().(*Ewah)
+		for _, i := range p {
+			bm.Set(i)
+		}
+
+		for _, n := range []int64{0, 1, 63, 64, 65, 128, 200, int64(r.Intn(1000))} {
+			for _, left := range []bool{true, false} {
+				var ans bitmap.Bitmap
+				size, offset := bm.Size()+n, n
+				if left {
+					ans = bm.ShiftLeft(n)
+				} else {
+					ans = bm.ShiftRight(n)
+					size, offset = bm.Size()-n, -n
+				}
+
+				if size < 0 {
+					size = 0
+				}
+
+				if ans.Size() != size {
+					t.Fatalf("seed %d, shift %d left %t: Size() = %d, expected %d", seed, n, left, ans.Size(), size)
+				}
+
+				expected := New()
+				for _, i := range p {
+					if i+offset >= 0 {
+						expected.Set(i + offset)
 					}
+				}
 
-					fmt.Printf("%d %d %d %d %d %d %.2f%% %d %d %d %.2f%% %d\n",
-						is[i], js[j], ks[k], ls[l],
-						m1.Size(), m1.SizeInWords(), (1-float64(m1.SizeInWords()*wordInBits)/float64(m1.Size()))*100, m1.Cardinality(),
-						m2.Size(), m2.SizeInWords(), (1-float64(m2.SizeInWords()*wordInBits)/float64(m2.Size()))*100, m2.Cardinality())
+				if !ans.(*Ewah).SameBits(expected) || ans.Cardinality() != expected.Cardinality() {
+					t.Fatalf("seed %d, shift %d left %t: wrong bits", seed, n, left)
+				}
 
-					m1.Reset()
-					m2.Reset()
+				if err := ans.(*Ewah).Validate(); err != nil {
+					t.Fatalf("seed %d, shift %d left %t: %v", seed, n, left, err)
 				}
 			}
 		}
 	}
 }
+
+func TestAppend(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		p1, p2 := bitmaptest.Positions(r), bitmaptest.Positions(r)
+
+		bm1, bm2 := New().(*Ewah), New().(*Ewah)
+		for _, i := range p1 {
+			bm1.Set(i)
+		}
+
+		for _, i := range p2 {
+			bm2.Set(i)
+		}
+
+		// Pad the first bitmap to a random size, sometimes a multiple of 64
+		size := bm1.Size() + int64(r.Intn(200)) + 1
+		if r.Intn(2) == 0 {
+			size = (size + 63) / 64 * 64
+		}
+
+		bm1 = bm1.Or(New().Set(size - 1).AndNot(New().Set(size - 1))).(*Ewah)
+
+		expected := New()
+		for _, i := range p1 {
+			expected.Set(i)
+		}
+
+		for _, i := range p2 {
+			expected.Set(size + i)
+		}
+
+		ans := bm1.Clone().(*Ewah).Append(bm2).(*Ewah)
+
+		if ans.Size() != size+bm2.Size() {
+			t.Fatalf("seed %d: Size() = %d, expected %d", seed, ans.Size(), size+bm2.Size())
+		}
+
+		if !ans.SameBits(expected) || ans.Cardinality() != expected.Cardinality() {
+			t.Fatalf("seed %d: wrong bits after Append", seed)
+		}
+
+		if err := ans.Validate(); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+
+		// Concat of EWAH bitmaps and a bitset
+		bs := bitset.New().Set(3)
+		c := bitmap.Concat(bm1, bm2, bs)
+
+		if c.Size() != size+bm2.Size()+4 || !c.Get(size+bm2.Size()+3) || !c.(*Ewah).SameBits(expected.Set(size+bm2.Size()+3)) {
+			t.Fatalf("seed %d: wrong bits after Concat", seed)
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		p := bitmaptest.Positions(r)
+
+		bm := New().(*Ewah)
+		for _, i := range p {
+			bm.Set(i)
+		}
+
+		for k := 0; k < 10; k++ {
+			start := r.Int63n(bm.Size() + 100)
+			if k%2 == 0 {
+				start = start / 64 * 64
+			}
+
+			end := start + r.Int63n(bm.Size()+100)
+
+			slice, rng := bm.Slice(start, end).(*Ewah), bm.Range(start, end).(*Ewah)
+
+			if end > bm.Size() {
+				end = bm.Size()
+			}
+
+			s, e := New(), New()
+			for _, i := range p {
+				if i >= start && i < end {
+					s.Set(i - start)
+					e.Set(i)
+				}
+			}
+
+			if (start < end && slice.Size() != end-start) || rng.Size() != end {
+				t.Fatalf("seed %d, [%d, %d): sizes %d and %d", seed, start, end, slice.Size(), rng.Size())
+			}
+
+			if !slice.SameBits(s) || slice.Cardinality() != s.Cardinality() {
+				t.Fatalf("seed %d: Slice(%d, %d) has the wrong bits", seed, start, end)
+			}
+
+			if !rng.SameBits(e) || rng.Cardinality() != e.Cardinality() {
+				t.Fatalf("seed %d: Range(%d, %d) has the wrong bits", seed, start, end)
+			}
+
+			if err := slice.Validate(); err != nil {
+				t.Fatalf("seed %d: Slice(%d, %d): %v", seed, start, end, err)
+			}
+
+			if err := rng.Validate(); err != nil {
+				t.Fatalf("seed %d: Range(%d, %d): %v", seed, start, end, err)
+			}
+		}
+	}
+}
+
+func TestRangeEmpty(t *testing.T) {
+	bm := New().Set(10).Set(100).Set(1000).(*Ewah)
+
+	// Empty ranges keep the size of the range, clipped to the size of the bitmap
+	for _, c := range [][3]int64{{5, 5, 5}, {100, 100, 100}, {640, 640, 640}, {500, 100, 100}, {2000, 1500, 1001},
+		{5000, 5000, 1001}, {-10, -10, 0}, {0, 0, 0}} {
+		rng := bm.Range(c[0], c[1])
+		if rng.Size() != c[2] || rng.Cardinality() != 0 {
+			t.Fatalf("Range(%d, %d): size %d, cardinality %d, expected size %d", c[0], c[1], rng.Size(),
+				rng.Cardinality(), c[2])
+		}
+
+		if err := rng.(*Ewah).Validate(); err != nil {
+			t.Fatalf("Range(%d, %d): %v", c[0], c[1], err)
+		}
+	}
+}
+
+func TestWordIterator(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		r := rand.New(rand.NewSource(seed))
+
+		bm := New().(*Ewah)
+		for _, i := range bitmaptest.Positions(r) {
+			bm.Set(i)
+		}
+
+		// Rebuild the uncompressed words from the runs and literal words
+		words := make([]uint64, 0, len(bm.Words()))
+
+		it := bm.WordIterator()
+		for it.Next() {
+			if it.Offset() != int64(len(words)) {
+				t.Fatalf("seed %d: Offset() = %d, expected %d", seed, it.Offset(), len(words))
+			}
+
+			bit, count := it.Run()
+			for k := int64(0); k < count; k++ {
+				if bit {
+					words = append(words, ^uint64(0))
+				} else {
+					words = append(words, 0)
+				}
+			}
+
+			words = append(words, it.Literals()...)
+		}
+
+		expected := bm.Words()
+		if len(words) != len(expected) {
+			t.Fatalf("seed %d: iterator has %d words, expected %d", seed, len(words), len(expected))
+		}
+
+		for k, w := range expected {
+			if w != words[k] {
+				t.Fatalf("seed %d: word %d = %x, expected %x", seed, k, words[k], w)
+			}
+		}
+	}
+
+	if New().(*Ewah).WordIterator().Next() {
+		t.Fatal("Empty bitmap should have no markers")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	for _, bits := range [][]int64{{}, {10}, {10, 64, 65, 1000, 5000, 5001}, {0, 1, 2, 63, 100000}} {
+		bm := New().(*Ewah)
+		for _, i := range bits {
+			bm.Set(i)
+		}
+
+		data, err := bm.Freeze().(*Frozen).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bm2 := New().(*Ewah)
+		if err := bm2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bm2.Equal(bm) {
+			t.Fatalf("Unmarshaled bitmap is not equal to %v", bits)
+		}
+
+		// Bits can still be set after unmarshaling
+		if bm2.Set(200000) == nil || !bm2.Get(200000) || bm2.Validate() != nil {
+			t.Fatal("Problem setting a bit after unmarshaling")
+		}
+
+		if bm2.UnmarshalBinary(data[:len(data)-1]) == nil {
+			t.Fatal("Unmarshaling truncated data should fail")
+		}
+	}
+
+	// A literal word that should have been a running length is rejected
+	data := []byte{64, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if err := New().(*Ewah).UnmarshalBinary(data); err == nil {
+		t.Fatal("Unmarshaling a non-canonical bitmap should fail")
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, data := range [][]byte{{0x80, 0x7f, 0x4f}, {0, 1, 2, 63, 64, 65}, {}} {
+		bm, _ := fuzzBitmap(data)
+		b, _ := bm.MarshalBinary()
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bm := New().(*Ewah)
+		if bm.UnmarshalBinary(data) != nil {
+			return
+		}
+
+		// Anything that decodes has to be usable
+		b, err := bm.MarshalBinary()
+		if err != nil || string(b) != string(data) {
+			t.Fatal("Encoding a decoded bitmap should give back the same data")
+		}
+
+		if c := bm.Not().Not().Cardinality(); c != bm.Cardinality() {
+			t.Fatalf("Cardinality changed to %d after Not twice", c)
+		}
+
+		if bm.Size() < 1<<20 {
+			bm.Set(bm.Size())
+			if err := bm.Validate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func FuzzMultiway(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 63, 64, 65}, []byte{1, 0x47, 0x80}, []byte{0x4f, 0x4f, 0xc3}, byte(0))
+	f.Add([]byte{0x80, 0x7f, 0x4f}, []byte{0x4f, 0x4f, 0x4f}, []byte{}, byte(1))
+	f.Add([]byte{0xc3, 0x4f}, []byte{0xc3, 0x4f}, []byte{0x41, 0xff}, byte(2))
+	f.Add([]byte{0xc3, 0x4f}, []byte{0xc3, 0x4f}, []byte{0xc3, 0x4f}, byte(3))
+
+	f.Fuzz(func(t *testing.T, a, b, c []byte, op byte) {
+		bm1, words1 := fuzzBitmap(a)
+		bm2, words2 := fuzzBitmap(b)
+		bm3, words3 := fuzzBitmap(c)
+
+		size := bm1.Size()
+		for _, bm := range []*Ewah{bm2, bm3} {
+			if bm.Size() > size {
+				size = bm.Size()
+			}
+		}
+
+		var ans bitmap.Bitmap
+		var f func(w []uint64) uint64
+
+		switch op % 4 {
+		case 0:
+			ans, f = bm1.And(bm2, bm3), func(w []uint64) uint64 { return w[0] & w[1] & w[2] }
+		case 1:
+			ans, f = bm1.Or(bm2, bm3), func(w []uint64) uint64 { return w[0] | w[1] | w[2] }
+		case 2:
+			ans, f = bm1.AndNot(bm2, bm3), func(w []uint64) uint64 { return w[0] &^ w[1] &^ w[2] }
+		case 3:
+			ans, f = bm1.Xor(bm2, bm3), func(w []uint64) uint64 { return w[0] ^ w[1] ^ w[2] }
+		}
+
+		fuzzCheck(t, fmt.Sprintf("op %d", op%4), ans.(*Ewah), fuzzModel(size, f, words1, words2, words3), size)
+	})
+}
+
+func TestAllRuns(t *testing.T) {
+	bm := New().(*Ewah)
+	bm.Set(1)
+	for i := int64(60); i < 300; i++ {
+		bm.Set(i)
+	}
+	bm.Set(302)
+	bm.Set(1000)
+
+	var runs []string
+	for start, n := range bm.Runs() {
+		runs = append(runs, fmt.Sprintf("%d+%d", start, n))
+	}
+
+	// The run from 60 goes through a literal word, running lengths of 1's, and another literal word
+	if fmt.Sprint(runs) != "[1+1 60+240 302+1 1000+1]" {
+		t.Fatalf("Runs() = %v", runs)
+	}
+
+	seen := make(map[int64]bool)
+	for i := range bm.Freeze().(*Frozen).All() {
+		seen[i] = true
+	}
+
+	if len(seen) != 243 || !seen[299] || seen[300] {
+		t.Fatalf("All() returned %d positions", len(seen))
+	}
+
+	words := fuzzModel(bm.Size(), func(w []uint64) uint64 { return ^w[0] }, bm.Words())
+	checkIterators(t, "Not", bm.Not().(*Ewah), words)
+
+	// Stopping early
+	for i := range bm.All() {
+		if i != 0 {
+			t.Fatalf("All() returned %d first after Not", i)
+		}
+		break
+	}
+}
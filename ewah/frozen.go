@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"github.com/reducedb/bitmap"
+	"iter"
+)
+
+// Frozen is an immutable copy of an Ewah bitmap. Set, Reset, Copy and Not panic with
+// bitmap.ErrFrozen. Reads do not move the bitmap's get cursor, so a Frozen bitmap can be shared
+// by multiple goroutines.
+type Frozen struct {
+	bm *Ewah
+
+	// markers holds the position of every marker word in the buffer, and starts the number of
+	// uncompressed words before it, so Get can binary search for the marker of a word
+	markers []int64
+	starts  []int64
+}
+
+var _ bitmap.Bitmap = (*Frozen)(nil)
+var _ bitmap.Cardinalities = (*Frozen)(nil)
+
+// Freeze returns an immutable copy of the bitmap. Later changes to the bitmap do not affect the
+// frozen copy.
+func (this *Ewah) Freeze() bitmap.Bitmap {
+	f := &Frozen{bm: this.Clone().(*Ewah)}
+	f.markers, f.starts = f.bm.markerIndex()
+
+	return f
+}
+
+func (this *Frozen) Freeze() bitmap.Bitmap {
+	return this
+}
+
+func (this *Frozen) Set(i int64) bitmap.Bitmap {
+	panic(bitmap.ErrFrozen)
+}
+
+// Get checks the bit at position i. It binary searches the marker words instead of using the
+// bitmap's get cursor, so it takes O(log m) time for m marker words regardless of access order.
+func (this *Frozen) Get(i int64) bool {
+	if i < 0 || i >= this.bm.sizeInBits {
+		return false
+	}
+
+	w := i / wordInBits
+
+	// Find the last marker that starts at or before the word
+	lo, hi := 0, len(this.starts)
+	for hi-lo > 1 {
+		mid := int(uint(lo+hi) >> 1)
+		if this.starts[mid] <= w {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	m := this.markers[lo]
+	rlw := this.bm.buffer[m]
+	w -= this.starts[lo]
+
+	empty := int64((rlw >> 1) & LargestRunningLengthCount)
+	if w < empty {
+		return rlw&1 != 0
+	}
+	w -= empty
+
+	if w < int64(rlw>>uint32(1+RunningLengthBits)) {
+		return this.bm.buffer[m+1+w]&(uint64(1)<<uint64(i%wordInBits)) != 0
+	}
+
+	return false
+}
+
+func (this *Frozen) Size() int64 {
+	return this.bm.Size()
+}
+
+func (this *Frozen) SizeInBytes() int64 {
+	return this.bm.SizeInBytes()
+}
+
+func (this *Frozen) SizeInWords() int64 {
+	return this.bm.SizeInWords()
+}
+
+func (this *Frozen) Stats() bitmap.Stats {
+	return this.bm.Stats()
+}
+
+func (this *Frozen) Validate() error {
+	return this.bm.Validate()
+}
+
+func (this *Frozen) Words() []uint64 {
+	return this.bm.Words()
+}
+
+func (this *Frozen) Reset() {
+	panic(bitmap.ErrFrozen)
+}
+
+// Clone returns a mutable copy of the frozen bitmap.
+func (this *Frozen) Clone() bitmap.Bitmap {
+	return this.bm.Clone()
+}
+
+func (this *Frozen) Copy(other bitmap.Bitmap) bitmap.Bitmap {
+	panic(bitmap.ErrFrozen)
+}
+
+func (this *Frozen) Equal(other bitmap.Bitmap) bool {
+	return this.bm.Equal(other)
+}
+
+func (this *Frozen) SameBits(other bitmap.Bitmap) bool {
+	return this.bm.SameBits(other)
+}
+
+func (this *Frozen) Hash() uint64 {
+	return this.bm.Hash()
+}
+
+func (this *Frozen) Cardinality() int64 {
+	return this.bm.Cardinality()
+}
+
+func (this *Frozen) IsSubsetOf(other bitmap.Bitmap) bool {
+	return this.bm.IsSubsetOf(other)
+}
+
+func (this *Frozen) IsSupersetOf(other bitmap.Bitmap) bool {
+	return this.bm.IsSupersetOf(other)
+}
+
+func (this *Frozen) Intersects(other bitmap.Bitmap) bool {
+	return this.bm.Intersects(other)
+}
+
+func (this *Frozen) IsDisjoint(other bitmap.Bitmap) bool {
+	return this.bm.IsDisjoint(other)
+}
+
+func (this *Frozen) AndCardinality(other bitmap.Bitmap) int64 {
+	return this.bm.AndCardinality(other)
+}
+
+func (this *Frozen) OrCardinality(other bitmap.Bitmap) int64 {
+	return this.bm.OrCardinality(other)
+}
+
+func (this *Frozen) AndNotCardinality(other bitmap.Bitmap) int64 {
+	return this.bm.AndNotCardinality(other)
+}
+
+func (this *Frozen) XorCardinality(other bitmap.Bitmap) int64 {
+	return this.bm.XorCardinality(other)
+}
+
+func (this *Frozen) And(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.bm.And(a...)
+}
+
+func (this *Frozen) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.bm.Or(a...)
+}
+
+func (this *Frozen) AndNot(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.bm.AndNot(a...)
+}
+
+func (this *Frozen) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.bm.Xor(a...)
+}
+
+func (this *Frozen) ShiftLeft(n int64) bitmap.Bitmap {
+	return this.bm.ShiftLeft(n)
+}
+
+func (this *Frozen) ShiftRight(n int64) bitmap.Bitmap {
+	return this.bm.ShiftRight(n)
+}
+
+func (this *Frozen) Slice(start, end int64) bitmap.Bitmap {
+	return this.bm.Slice(start, end)
+}
+
+func (this *Frozen) Range(start, end int64) bitmap.Bitmap {
+	return this.bm.Range(start, end)
+}
+
+func (this *Frozen) WordIterator() *WordIterator {
+	return this.bm.WordIterator()
+}
+
+func (this *Frozen) All() iter.Seq[int64] {
+	return this.bm.All()
+}
+
+// Backward returns an iterator over the positions of the bits set, in descending order. The
+// markers were indexed when the bitmap was frozen, so they are not collected again.
+func (this *Frozen) Backward() iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		this.bm.backward(this.markers, this.starts, yield)
+	}
+}
+
+func (this *Frozen) Runs() iter.Seq2[int64, int64] {
+	return this.bm.Runs()
+}
+
+func (this *Frozen) Not() bitmap.Bitmap {
+	panic(bitmap.ErrFrozen)
+}
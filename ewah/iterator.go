@@ -0,0 +1,237 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// WordIterator walks the compressed words of an EWAH bitmap one marker word at a time. Each marker
+// stands for a running length of words that are all 0's or all 1's, followed by literal words that
+// are stored as is. It's meant for writing operators that work on the compressed form directly:
+//
+//	it := bm.WordIterator()
+//	for it.Next() {
+//		bit, count := it.Run()
+//		literals := it.Literals()
+//		...
+//	}
+//
+// The bitmap must not be modified while it's being iterated over.
+type WordIterator struct {
+	c *cursor
+
+	// offset is the number of uncompressed words before the current marker
+	offset int64
+
+	started bool
+}
+
+// WordIterator returns an iterator over the marker words of the bitmap.
+func (this *Ewah) WordIterator() *WordIterator {
+	return &WordIterator{
+		c: newCursor(this.buffer, this.actualSizeInWords),
+	}
+}
+
+// Next moves to the next marker word. It returns false when there are no more markers.
+func (this *WordIterator) Next() bool {
+	if !this.started {
+		this.started = true
+
+		// An empty bitmap only has a marker with no words
+		return this.c.size() > 0 || !this.c.lastMarker()
+	}
+
+	if this.c.lastMarker() {
+		return false
+	}
+
+	this.offset += this.c.size()
+
+	return this.c.nextMarker() == nil
+}
+
+// Run returns the running length of the current marker: whether the words are all 1's or all 0's,
+// and how many there are.
+func (this *WordIterator) Run() (bool, int64) {
+	return this.c.emptyBit(), this.c.emptyCount()
+}
+
+// Literals returns the literal words that come after the running length of the current marker. The
+// slice points into the bitmap and must not be modified.
+func (this *WordIterator) Literals() []uint64 {
+	start := this.c.marker + 1
+	end := start + this.c.literalCount()
+
+	return this.c.buffer[start:end:end]
+}
+
+// Offset returns the position, in uncompressed words, of the first word of the current marker. Bit
+// i of the bitmap is in word i/64.
+func (this *WordIterator) Offset() int64 {
+	return this.offset
+}
+
+// All returns an iterator over the positions of the bits set, in ascending order:
+//
+//	for i := range bm.All() {
+//		...
+//	}
+//
+// The bitmap must not be modified while it's being iterated over.
+func (this *Ewah) All() iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		this.runs(func(start, n int64) bool {
+			for i := start; i < start+n; i++ {
+				if !yield(i) {
+					return false
+				}
+			}
+
+			return true
+		})
+	}
+}
+
+// Backward returns an iterator over the positions of the bits set, in descending order. Marker words
+// only point forward, so the positions of the markers are collected first, which takes memory for
+// every marker word rather than for every bit set. The bitmap must not be modified while it's being
+// iterated over.
+func (this *Ewah) Backward() iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		markers, starts := this.markerIndex()
+		this.backward(markers, starts, yield)
+	}
+}
+
+// backward calls yield with the positions of the bits set in descending order, until yield returns
+// false, walking the markers of the index from the last one
+func (this *Ewah) backward(markers, starts []int64, yield func(int64) bool) {
+	for k := len(markers) - 1; k >= 0; k-- {
+		m := markers[k]
+		empty := int64((this.buffer[m] >> 1) & LargestRunningLengthCount)
+		literals := int64(this.buffer[m] >> uint32(1+RunningLengthBits))
+
+		for j := literals - 1; j >= 0; j-- {
+			base := (starts[k] + empty + j) * wordInBits
+
+			for w := this.buffer[m+1+j]; w != 0; {
+				b := 63 - bits.LeadingZeros64(w)
+				w &^= uint64(1) << uint(b)
+
+				if i := base + int64(b); i < this.sizeInBits && !yield(i) {
+					return
+				}
+			}
+		}
+
+		if this.buffer[m]&1 != 0 {
+			end := (starts[k] + empty) * wordInBits
+			if end > this.sizeInBits {
+				end = this.sizeInBits
+			}
+
+			for i := end - 1; i >= starts[k]*wordInBits; i-- {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// markerIndex returns the position of every marker word in the buffer, and the number of
+// uncompressed words before each one
+func (this *Ewah) markerIndex() (markers, starts []int64) {
+	c := newCursor(this.buffer, this.actualSizeInWords)
+
+	for words := int64(0); ; {
+		markers = append(markers, c.marker)
+		starts = append(starts, words)
+		words += c.emptyCount() + c.literalCount()
+
+		if c.nextMarker() != nil {
+			return markers, starts
+		}
+	}
+}
+
+// Runs returns an iterator over the runs of consecutive bits set, as the position of the first bit
+// and the number of bits, in ascending order. Running lengths of 1's are returned without going
+// through their bits, and runs that span several words come out as one.
+func (this *Ewah) Runs() iter.Seq2[int64, int64] {
+	return func(yield func(int64, int64) bool) {
+		this.runs(yield)
+	}
+}
+
+// runs calls f with every maximal run of 1's, until f returns false
+func (this *Ewah) runs(f func(start, n int64) bool) {
+	start, n := int64(0), int64(0)
+
+	// add extends the current run if the next one starts right after it, or hands it to f
+	add := func(s, m int64) bool {
+		if n > 0 && s == start+n {
+			n += m
+			return true
+		}
+
+		if n > 0 && !f(start, n) {
+			return false
+		}
+
+		start, n = s, m
+		return true
+	}
+
+	for it := this.WordIterator(); it.Next(); {
+		bit, count := it.Run()
+		pos := it.Offset() * wordInBits
+
+		if bit && count > 0 {
+			m := count * wordInBits
+			if pos+m > this.sizeInBits {
+				m = this.sizeInBits - pos
+			}
+
+			if !add(pos, m) {
+				return
+			}
+		}
+
+		pos += count * wordInBits
+
+		for j, w := range it.Literals() {
+			if !literalRuns(pos+int64(j)*wordInBits, w, add) {
+				return
+			}
+		}
+	}
+
+	if n > 0 {
+		f(start, n)
+	}
+}
+
+// literalRuns calls add with the runs of 1's in a literal word at position base, until add returns
+// false
+func literalRuns(base int64, w uint64, add func(start, n int64) bool) bool {
+	for w != 0 {
+		tz := bits.TrailingZeros64(w)
+		ones := bits.TrailingZeros64(^(w >> uint(tz)))
+
+		if !add(base+int64(tz), int64(ones)) {
+			return false
+		}
+
+		w &^= (uint64(1)<<uint(ones) - 1) << uint(tz)
+	}
+
+	return true
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+)
+
+var _ encoding.BinaryMarshaler = (*Ewah)(nil)
+var _ encoding.BinaryUnmarshaler = (*Ewah)(nil)
+
+// MarshalBinary encodes the bitmap as its size in bits followed by the words of the compressed
+// buffer, all as little endian 64-bit words.
+func (this *Ewah) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 8*(this.actualSizeInWords+1))
+	binary.LittleEndian.PutUint64(data, uint64(this.sizeInBits))
+
+	for i := int64(0); i < this.actualSizeInWords; i++ {
+		binary.LittleEndian.PutUint64(data[8*(i+1):], this.buffer[i])
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes a bitmap encoded by MarshalBinary, replacing the bitmap's contents. The
+// decoded bitmap is checked with Validate, so corrupted data returns an error instead of a bitmap
+// that misbehaves later.
+func (this *Ewah) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 || len(data)%8 != 0 {
+		return fmt.Errorf("ewah/UnmarshalBinary: invalid length %d", len(data))
+	}
+
+	tmp := New().(*Ewah)
+	tmp.sizeInBits = int64(binary.LittleEndian.Uint64(data))
+	tmp.actualSizeInWords = int64(len(data)/8 - 1)
+	tmp.buffer = make([]uint64, tmp.actualSizeInWords)
+
+	for i := range tmp.buffer {
+		tmp.buffer[i] = binary.LittleEndian.Uint64(data[8*(i+1):])
+	}
+
+	if err := tmp.Validate(); err != nil {
+		return err
+	}
+
+	// New bits are set from the last marker
+	c := newCursor(tmp.buffer, tmp.actualSizeInWords)
+	for c.nextMarker() == nil {
+	}
+
+	tmp.setCursor.resetMarker(tmp.buffer, tmp.actualSizeInWords, c.marker)
+	tmp.getCursor.reset(tmp.buffer, tmp.actualSizeInWords)
+
+	*this = *tmp
+	return nil
+}
+
+func (this *Frozen) MarshalBinary() ([]byte, error) {
+	return this.bm.MarshalBinary()
+}
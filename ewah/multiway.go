@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"github.com/reducedb/bitmap"
+)
+
+type wordOp int
+
+const (
+	andOp wordOp = iota
+	orOp
+	andNotOp
+	xorOp
+)
+
+// absorbs returns true if word w of operand i decides the result on its own, like a word of 0's for
+// AND, and the word it decides it to
+func (this wordOp) absorbs(i int, w uint64) (bool, uint64) {
+	switch this {
+	case andOp:
+		return w == 0, 0
+	case orOp:
+		return w == ^uint64(0), ^uint64(0)
+	case andNotOp:
+		return (i == 0 && w == 0) || (i > 0 && w == ^uint64(0)), 0
+	}
+
+	return false, 0
+}
+
+func (this wordOp) apply(i int, acc, w uint64) uint64 {
+	if i == 0 {
+		return w
+	}
+
+	switch this {
+	case andOp:
+		return acc & w
+	case orOp:
+		return acc | w
+	case andNotOp:
+		return acc &^ w
+	}
+
+	return acc ^ w
+}
+
+// multiway applies op to all the bitmaps at once, in a single pass over their cursors, instead of
+// creating an intermediate bitmap for every operand. When an operand is in a running length that
+// decides the result, like 0's for AND, all the cursors skip over it together. The result is the size
+// of the largest bitmap.
+func multiway(op wordOp, a []bitmap.Bitmap) bitmap.Bitmap {
+	size := int64(0)
+	cursors := make([]*cursor, len(a))
+
+	for i, v := range a {
+		b, ok := toEwah(v)
+		if !ok {
+			return nil
+		}
+
+		if b.sizeInBits > size {
+			size = b.sizeInBits
+		}
+
+		cursors[i] = newCursor(b.buffer, b.actualSizeInWords)
+		cursors[i].moveForward(0)
+	}
+
+	w := newWordWriter(New().(*Ewah), size)
+
+	for !w.full() {
+		word, step := uint64(0), w.max-w.words
+		absorbed, skip := false, int64(0)
+
+		for i, c := range cursors {
+			v, n := c.current()
+
+			// Bitmaps that have run out are 0's until the end
+			if n == 0 {
+				v, n = 0, w.max-w.words
+			}
+
+			if ok, r := op.absorbs(i, v); ok {
+				if !absorbed || n > skip {
+					skip = n
+				}
+				absorbed, word = true, r
+			}
+
+			if !absorbed {
+				word = op.apply(i, word, v)
+				if n < step {
+					step = n
+				}
+			}
+		}
+
+		if absorbed {
+			step = skip
+		}
+
+		w.write(word, step)
+
+		for _, c := range cursors {
+			c.moveForward(step)
+		}
+	}
+
+	return w.done()
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"github.com/reducedb/bitmap"
+)
+
+// IsSubsetOf returns true if every bit set in this bitmap is also set in other. A nil bitmap is
+// empty, so an empty bitmap is a subset of nil.
+func (this *Ewah) IsSubsetOf(other bitmap.Bitmap) bool {
+	return !this.anyWords(asEwah(other), func(x, y uint64) bool {
+		return x&^y != 0
+	})
+}
+
+// IsSupersetOf returns true if every bit set in other is also set in this bitmap.
+func (this *Ewah) IsSupersetOf(other bitmap.Bitmap) bool {
+	return !this.anyWords(asEwah(other), func(x, y uint64) bool {
+		return y&^x != 0
+	})
+}
+
+// Intersects returns true if at least one bit is set in both this bitmap and other. It stops at the
+// first such bit, so it's faster than checking the cardinality of And.
+func (this *Ewah) Intersects(other bitmap.Bitmap) bool {
+	return this.anyWords(asEwah(other), func(x, y uint64) bool {
+		return x&y != 0
+	})
+}
+
+// IsDisjoint returns true if no bit is set in both this bitmap and other.
+func (this *Ewah) IsDisjoint(other bitmap.Bitmap) bool {
+	return !this.Intersects(other)
+}
+
+// anyWords walks the words of this bitmap and a side by side, and returns true as soon as f returns
+// true for a pair of words at the same position. Running lengths are compared a whole run at a time,
+// and words past the end of the shorter bitmap are 0's.
+func (this *Ewah) anyWords(a *Ewah, f func(x, y uint64) bool) bool {
+	i := newCursor(this.buffer, this.actualSizeInWords)
+	j := newCursor(a.buffer, a.actualSizeInWords)
+
+	for {
+		x, nx := i.current()
+		y, ny := j.current()
+
+		if nx == 0 && ny == 0 {
+			return false
+		}
+
+		if f(x, y) {
+			return true
+		}
+
+		// Move both cursors past the words that repeat in both
+		n := nx
+		if nx == 0 || (ny > 0 && ny < nx) {
+			n = ny
+		}
+
+		if nx > 0 {
+			i.moveForward(n)
+		}
+
+		if ny > 0 {
+			j.moveForward(n)
+		}
+	}
+}
+
+// asEwah returns b as an EWAH bitmap, converting it if it's not one. A nil bitmap is empty.
+func asEwah(b bitmap.Bitmap) *Ewah {
+	if b == nil {
+		return New().(*Ewah)
+	}
+
+	if e, ok := toEwah(b); ok {
+		return e
+	}
+
+	if w, ok := b.(interface {
+		Words() []uint64
+	}); ok {
+		return FromWords(w.Words(), b.Size()).(*Ewah)
+	}
+
+	return bitmap.Convert(b, New).(*Ewah)
+}
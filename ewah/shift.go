@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"github.com/reducedb/bitmap"
+	"math"
+)
+
+// ShiftLeft returns a new bitmap with every bit moved n positions up, and a size n bits larger. A
+// negative n shifts right. Shifting by a multiple of 64 just adds a running length of 0's in front;
+// otherwise the bits of each word are carried over into the next. It returns nil if the new size is
+// more than an EWAH bitmap can hold.
+func (this *Ewah) ShiftLeft(n int64) bitmap.Bitmap {
+	if n < 0 {
+		return this.ShiftRight(-n)
+	}
+
+	size := this.sizeInBits + n
+	if size > math.MaxInt32 {
+		return nil
+	}
+
+	q, r := n/wordInBits, uint64(n%wordInBits)
+
+	w := newWordWriter(New().(*Ewah), size)
+	w.write(0, q)
+
+	c := newCursor(this.buffer, this.actualSizeInWords)
+	carry := uint64(0)
+
+	for {
+		word, m := c.current()
+		if m == 0 {
+			break
+		}
+
+		if r == 0 {
+			w.write(word, m)
+		} else {
+			w.write(word<<r|carry, 1)
+
+			// Only running lengths repeat, and a word of all 0's or all 1's is the same after the
+			// bits carried over from the same word
+			w.write(word, m-1)
+			carry = word >> (uint64(wordInBits) - r)
+		}
+
+		c.moveForward(m)
+	}
+
+	w.write(carry, 1)
+
+	return w.done()
+}
+
+// ShiftRight returns a new bitmap with every bit moved n positions down, and a size n bits smaller.
+// Bits that would move below 0 are dropped. A negative n shifts left. Shifting by a multiple of 64
+// just drops words from the front.
+func (this *Ewah) ShiftRight(n int64) bitmap.Bitmap {
+	if n < 0 {
+		return this.ShiftLeft(-n)
+	}
+
+	return this.Slice(n, this.sizeInBits)
+}
+
+// wordWriter adds a stream of uncompressed words, which can repeat, to the end of an EWAH bitmap
+// until it's a given size. Words past the size are dropped, and missing words at the end are 0's.
+type wordWriter struct {
+	bm    *Ewah
+	size  int64
+	words int64
+	max   int64
+}
+
+// newWordWriter creates a writer that adds words to bm, which must end on a word boundary, until it's
+// size bits.
+func newWordWriter(bm *Ewah, size int64) *wordWriter {
+	return &wordWriter{
+		bm:    bm,
+		size:  size,
+		words: bm.sizeInBits / wordInBits,
+		max:   (size + wordInBits - 1) / wordInBits,
+	}
+}
+
+// write adds n copies of word. The bits of the last word past the size are cleared.
+func (this *wordWriter) write(word uint64, n int64) {
+	if n > this.max-this.words {
+		n = this.max - this.words
+	}
+
+	if n <= 0 {
+		return
+	}
+
+	if lastBits := this.size % wordInBits; lastBits != 0 && this.words+n == this.max {
+		this.add(word, n-1)
+		this.add(word&(^uint64(0)>>uint64(wordInBits-lastBits)), 1)
+		return
+	}
+
+	this.add(word, n)
+}
+
+// full returns true if no more words can be added
+func (this *wordWriter) full() bool {
+	return this.words >= this.max
+}
+
+func (this *wordWriter) add(word uint64, n int64) {
+	if n <= 0 {
+		return
+	}
+
+	this.words += n
+
+	if word == 0 || word == ^uint64(0) {
+		this.bm.addStreamOfEmptyWords(word != 0, n)
+		return
+	}
+
+	for ; n > 0; n-- {
+		this.bm.addLiteralWord(word)
+		this.bm.sizeInBits += wordInBits
+	}
+}
+
+// done pads the bitmap with 0's up to its size, and returns it
+func (this *wordWriter) done() bitmap.Bitmap {
+	this.write(0, this.max-this.words)
+	this.bm.sizeInBits = this.size
+
+	return this.bm
+}
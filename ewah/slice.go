@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package ewah
+
+import (
+	"github.com/reducedb/bitmap"
+)
+
+// Slice returns a new bitmap with the bits in [start, end), moved down by start so bit start becomes
+// bit 0. The size of the new bitmap is end-start. The range is clipped to the size of this bitmap.
+// The words before start are skipped without uncompressing them, and if start is a multiple of 64
+// the words in the range are copied over as they are.
+func (this *Ewah) Slice(start, end int64) bitmap.Bitmap {
+	if start < 0 {
+		start = 0
+	}
+
+	if end > this.sizeInBits {
+		end = this.sizeInBits
+	}
+
+	if start >= end {
+		return New()
+	}
+
+	q, r := start/wordInBits, uint64(start%wordInBits)
+
+	c := newCursor(this.buffer, this.actualSizeInWords)
+	c.moveForward(q)
+
+	w := newWordWriter(New().(*Ewah), end-start)
+
+	if r == 0 {
+		// All but the last word can be copied as they are, the last one may have to be cleared
+		// past the end
+		n, _ := c.copyForward(w.bm, w.max-1, false)
+		w.words += n
+
+		word, _ := c.current()
+		w.write(word, 1)
+
+		return w.done()
+	}
+
+	// Each word is made of the high bits of one word and the low bits of the next
+	prev, started := uint64(0), false
+
+	for !w.full() {
+		word, m := c.current()
+		if m == 0 {
+			break
+		}
+
+		if started {
+			w.write(prev>>r|word<<(uint64(wordInBits)-r), 1)
+		}
+
+		// Only running lengths repeat, and words of all 0's or all 1's are the same after mixing
+		// their bits with the next word of the same run
+		w.write(word, m-1)
+		prev, started = word, true
+
+		c.moveForward(m)
+	}
+
+	if started {
+		w.write(prev>>r, 1)
+	}
+
+	return w.done()
+}
+
+// Range returns a new bitmap with only the bits in [start, end), which stay where they are. The size
+// of the new bitmap is end, even if the range is empty. The range is clipped to the size of this
+// bitmap.
+func (this *Ewah) Range(start, end int64) bitmap.Bitmap {
+	if start < 0 {
+		start = 0
+	}
+
+	if end > this.sizeInBits {
+		end = this.sizeInBits
+	}
+
+	if start >= end {
+		if end < 0 {
+			end = 0
+		}
+
+		return newWordWriter(New().(*Ewah), end).done()
+	}
+
+	q := start / wordInBits
+
+	c := newCursor(this.buffer, this.actualSizeInWords)
+	c.moveForward(q)
+
+	w := newWordWriter(New().(*Ewah), end)
+	w.write(0, q)
+
+	// The first word is cleared before start
+	word, _ := c.current()
+	w.write(word&(^uint64(0)<<uint64(start%wordInBits)), 1)
+	c.moveForward(1)
+
+	// The words in between are copied as they are, and the last one is cleared past the end
+	n, _ := c.copyForward(w.bm, w.max-w.words-1, false)
+	w.words += n
+
+	word, _ = c.current()
+	w.write(word, 1)
+
+	return w.done()
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap
+
+type exprOp int
+
+const (
+	leafOp exprOp = iota
+	andOp
+	orOp
+	andNotOp
+	xorOp
+	notOp
+)
+
+// Expr is a lazy expression over bitmaps. And, Or, AndNot, Xor and Not build a tree instead of
+// computing a result, and the tree is evaluated the first time its bits are read, by Get, Size,
+// Cardinality, the predicates, Clone or Eval. Chains of the same operation are flattened into a
+// single call with all the operands, which implementations like EWAH evaluate in one pass instead of
+// creating an intermediate bitmap for every operand:
+//
+//	e := bitmap.Lazy(a).And(b).And(c).AndNot(d)
+//	n := e.Cardinality() // a.And(b, c).AndNot(d)
+//
+// Only chains of the same operation are fused. Where the operation changes, as in (a AND b) OR c, the
+// inner chain is evaluated into an intermediate bitmap that becomes an operand of the outer one.
+//
+// The bitmaps in the tree must not be modified until it's evaluated. An Expr can't be modified
+// either: Set, Reset and Copy panic with ErrFrozen.
+type Expr struct {
+	op exprOp
+	x  []Bitmap
+
+	// result is the evaluated expression, nil until it's needed
+	result Bitmap
+}
+
+var _ Bitmap = (*Expr)(nil)
+var _ Cardinalities = (*Expr)(nil)
+
+// Lazy returns an expression of a single bitmap, to build a larger expression from.
+func Lazy(b Bitmap) *Expr {
+	return &Expr{op: leafOp, x: []Bitmap{b}}
+}
+
+// Eval evaluates the expression, once, and returns the result. The result is shared with the
+// expression, so it must not be modified; Clone returns a copy that can be.
+func (this *Expr) Eval() Bitmap {
+	if this.result == nil {
+		this.result = this.eval()
+	}
+
+	return this.result
+}
+
+func (this *Expr) eval() Bitmap {
+	switch this.op {
+	case leafOp:
+		return this.x[0]
+	case notOp:
+		// Not flips the bits in place, so it works on a copy
+		return evaluated(this.x[0]).Clone().Not()
+	}
+
+	a := this.flatten(nil)
+	if len(a) == 1 {
+		return a[0]
+	}
+
+	switch this.op {
+	case andOp:
+		return a[0].And(a[1:]...)
+	case orOp:
+		return a[0].Or(a[1:]...)
+	case andNotOp:
+		return a[0].AndNot(a[1:]...)
+	}
+
+	return a[0].Xor(a[1:]...)
+}
+
+// flatten appends the operands of the chain of operations of the same kind as this one to a, with the
+// other operands evaluated. For AndNot, only the first operand can continue the chain.
+func (this *Expr) flatten(a []Bitmap) []Bitmap {
+	for i, v := range this.x {
+		if e, ok := v.(*Expr); ok && e.result == nil && e.op == this.op && (i == 0 || this.op != andNotOp) {
+			a = e.flatten(a)
+		} else {
+			a = append(a, evaluated(v))
+		}
+	}
+
+	return a
+}
+
+// evaluated returns the result of b if it's an expression, or b itself
+func evaluated(b Bitmap) Bitmap {
+	if e, ok := b.(*Expr); ok {
+		return e.Eval()
+	}
+
+	return b
+}
+
+func (this *Expr) apply(op exprOp, a []Bitmap) Bitmap {
+	return &Expr{op: op, x: append([]Bitmap{this}, a...)}
+}
+
+func (this *Expr) And(a ...Bitmap) Bitmap {
+	return this.apply(andOp, a)
+}
+
+func (this *Expr) Or(a ...Bitmap) Bitmap {
+	return this.apply(orOp, a)
+}
+
+func (this *Expr) AndNot(a ...Bitmap) Bitmap {
+	return this.apply(andNotOp, a)
+}
+
+func (this *Expr) Xor(a ...Bitmap) Bitmap {
+	return this.apply(xorOp, a)
+}
+
+func (this *Expr) Not() Bitmap {
+	return &Expr{op: notOp, x: []Bitmap{this}}
+}
+
+func (this *Expr) Set(i int64) Bitmap {
+	panic(ErrFrozen)
+}
+
+func (this *Expr) Reset() {
+	panic(ErrFrozen)
+}
+
+func (this *Expr) Copy(other Bitmap) Bitmap {
+	panic(ErrFrozen)
+}
+
+func (this *Expr) Get(i int64) bool {
+	return this.Eval().Get(i)
+}
+
+func (this *Expr) Size() int64 {
+	return this.Eval().Size()
+}
+
+// Clone returns a copy of the result of the expression, which can be modified.
+func (this *Expr) Clone() Bitmap {
+	return this.Eval().Clone()
+}
+
+func (this *Expr) Equal(other Bitmap) bool {
+	return this.Eval().Equal(evaluated(other))
+}
+
+func (this *Expr) Cardinality() int64 {
+	return this.Eval().Cardinality()
+}
+
+func (this *Expr) IsSubsetOf(other Bitmap) bool {
+	return this.Eval().IsSubsetOf(evaluated(other))
+}
+
+func (this *Expr) IsSupersetOf(other Bitmap) bool {
+	return this.Eval().IsSupersetOf(evaluated(other))
+}
+
+func (this *Expr) Intersects(other Bitmap) bool {
+	return this.Eval().Intersects(evaluated(other))
+}
+
+func (this *Expr) IsDisjoint(other Bitmap) bool {
+	return this.Eval().IsDisjoint(evaluated(other))
+}
+
+func (this *Expr) AndCardinality(other Bitmap) int64 {
+	return AndCardinality(this.Eval(), evaluated(other))
+}
+
+func (this *Expr) OrCardinality(other Bitmap) int64 {
+	return OrCardinality(this.Eval(), evaluated(other))
+}
+
+func (this *Expr) AndNotCardinality(other Bitmap) int64 {
+	if c, ok := this.Eval().(Cardinalities); ok {
+		return c.AndNotCardinality(evaluated(other))
+	}
+
+	return this.Eval().AndNot(evaluated(other)).Cardinality()
+}
+
+func (this *Expr) XorCardinality(other Bitmap) int64 {
+	return Hamming(this.Eval(), evaluated(other))
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap_test
+
+import (
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/ewah"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	a, b, c := ewah.New(), ewah.New(), ewah.New()
+	for i := int64(0); i < 10000; i++ {
+		if i%2 == 0 {
+			a.Set(i)
+		}
+		if i%3 == 0 {
+			b.Set(i)
+		}
+		if i%5 == 0 || i > 8000 {
+			c.Set(i)
+		}
+	}
+
+	e := bitmap.Lazy(a).And(b).And(c.(*ewah.Ewah).Freeze()).AndNot(bitmap.Lazy(c).Not())
+	if ans := a.And(b, c); !e.Equal(ans) || !ans.Equal(e) || e.Cardinality() != ans.Cardinality() {
+		t.Fatal("Lazy And and AndNot should be the same as And")
+	}
+
+	e = bitmap.Lazy(a).Or(b).Xor(bitmap.Lazy(c).Or(a))
+	ans := a.Or(b).Xor(c.Or(a))
+	for i := int64(0); i < 10001; i++ {
+		if e.Get(i) != ans.Get(i) {
+			t.Fatalf("Lazy Or and Xor: Get(%d) = %t", i, e.Get(i))
+		}
+	}
+
+	if !e.IsSubsetOf(ans) || !e.IsSupersetOf(bitmap.Lazy(ans)) || e.Size() != ans.Size() {
+		t.Fatal("Lazy Or and Xor should be the same as Or and Xor")
+	}
+
+	// The clone can be modified, without changing the expression
+	clone := e.Clone()
+	clone.Set(20000)
+	if e.Get(20000) || !clone.Get(20000) {
+		t.Fatal("Clone of an expression should be independent of it")
+	}
+
+	defer func() {
+		if recover() != bitmap.ErrFrozen {
+			t.Fatal("Set on an expression should panic with ErrFrozen")
+		}
+	}()
+
+	e.Set(20000)
+}
+
+// counted is a bitmap that records the bitwise operations it's asked to do, and the number of
+// operands of each
+type counted struct {
+	bitmap.Bitmap
+	calls *[]string
+}
+
+func (this counted) op(name string, f func(...bitmap.Bitmap) bitmap.Bitmap, a []bitmap.Bitmap) bitmap.Bitmap {
+	*this.calls = append(*this.calls, fmt.Sprintf("%s %d", name, len(a)+1))
+
+	inner := make([]bitmap.Bitmap, len(a))
+	for i, v := range a {
+		inner[i] = v.(counted).Bitmap
+	}
+
+	return counted{f(inner...), this.calls}
+}
+
+func (this counted) And(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.op("and", this.Bitmap.And, a)
+}
+
+func (this counted) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.op("or", this.Bitmap.Or, a)
+}
+
+func (this counted) AndNot(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.op("andnot", this.Bitmap.AndNot, a)
+}
+
+func (this counted) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
+	return this.op("xor", this.Bitmap.Xor, a)
+}
+
+func TestExprIntermediates(t *testing.T) {
+	var calls []string
+	bms := make([]bitmap.Bitmap, 7)
+	for i := range bms {
+		bms[i] = counted{ewah.New().Set(int64(i)).Set(10).Set(int64(20 + i)), &calls}
+	}
+	a, b, c, d, e, f, g := bms[0], bms[1], bms[2], bms[3], bms[4], bms[5], bms[6]
+
+	// Each chain of the same operation is one call, and only the chains inside a different operation
+	// create intermediate bitmaps
+	x := bitmap.Lazy(a).And(b).And(c).Or(d).Or(bitmap.Lazy(e).Xor(f)).AndNot(g)
+	if n := x.Cardinality(); n != 6 {
+		t.Fatalf("Cardinality = %d, expected 6", n)
+	}
+
+	if fmt.Sprint(calls) != "[and 3 xor 2 or 3 andnot 2]" {
+		t.Fatalf("Operations = %v", calls)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap
+
+const (
+	fnvOffset uint64 = 14695981039346656037
+	fnvPrime  uint64 = 1099511628211
+)
+
+// wordser is implemented by bitmaps that can uncompress themselves into 64-bit words, where bit i is
+// stored in word i/64 at position i%64.
+type wordser interface {
+	Words() []uint64
+}
+
+// SameBits returns true if a and b have the same bits set. Unlike Equal, it ignores the size of the
+// bitmaps beyond the last bit set, and works across implementations.
+func SameBits(a, b Bitmap) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	wa, wb := trim(words(a)), trim(words(b))
+	if len(wa) != len(wb) {
+		return false
+	}
+
+	for i, v := range wa {
+		if wb[i] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Hash returns a 64-bit FNV-1a hash of the positions of the bits set in b. Bitmaps with the same bits
+// set have the same hash, whatever their size or implementation, so the hash can be used as a cache
+// key. It doesn't change between runs or versions.
+func Hash(b Bitmap) uint64 {
+	h := fnvOffset
+
+	for i, v := range words(b) {
+		if v == 0 {
+			continue
+		}
+
+		// Hash the index of each non-zero word along with the word, so trailing and skipped zero
+		// words don't change the hash
+		for _, x := range [2]uint64{uint64(i), v} {
+			for k := uint(0); k < 64; k += 8 {
+				h ^= (x >> k) & 0xff
+				h *= fnvPrime
+			}
+		}
+	}
+
+	return h
+}
+
+// words returns the bits of b as 64-bit words, using the implementation's own Words method if it has
+// one.
+func words(b Bitmap) []uint64 {
+	if w, ok := b.(wordser); ok {
+		return w.Words()
+	}
+
+	w := make([]uint64, (b.Size()+63)/64)
+	for i, n := int64(0), b.Size(); i < n; i++ {
+		if b.Get(i) {
+			w[i/64] |= 1 << uint64(i%64)
+		}
+	}
+
+	return w
+}
+
+// trim drops the trailing zero words
+func trim(w []uint64) []uint64 {
+	n := len(w)
+	for n > 0 && w[n-1] == 0 {
+		n -= 1
+	}
+
+	return w[:n]
+}
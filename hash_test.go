@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap_test
+
+import (
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/ewah"
+	"testing"
+)
+
+func TestSameBits(t *testing.T) {
+	bm := ewah.New().(*ewah.Ewah)
+	bm2 := ewah.New().(*ewah.Ewah)
+	bs := bitset.New()
+
+	for _, i := range []int64{10, 64, 65, 1000, 5000, 5001} {
+		bm.Set(i)
+		bm2.Set(i)
+		bs.Set(i)
+	}
+
+	bm2.Set(6000)
+
+	// Or with an empty bitmap of 6001 bits pads the result with 0's, which changes the size but not
+	// the bits that are set
+	bm3 := bm.Or(ewah.New().Set(6000).AndNot(ewah.New().Set(6000)))
+
+	if bm.Equal(bm3) {
+		t.Fatal("Bitmaps of different sizes should not be equal")
+	}
+
+	if !bm.SameBits(bm3) || !bm3.(*ewah.Ewah).SameBits(bm) || !bitmap.SameBits(bm3, bm) {
+		t.Fatal("Bitmaps with the same bits set should have the same bits")
+	}
+
+	if !bm.SameBits(bs) || !bm.SameBits(bs.(*bitset.Bitset).Freeze()) || !bitmap.SameBits(bs, bm3) {
+		t.Fatal("EWAH bitmap and bitset with the same bits set should have the same bits")
+	}
+
+	if bm.SameBits(bm2) || bitmap.SameBits(bs, bm2) {
+		t.Fatal("Bitmaps with different bits set should not have the same bits")
+	}
+
+	if bm.Hash() != bm3.(*ewah.Ewah).Hash() || bm.Hash() != bs.(*bitset.Bitset).Hash() || bm.Hash() != bitmap.Hash(bs) {
+		t.Fatal("Bitmaps with the same bits set should have the same hash")
+	}
+
+	if bm.Hash() == bm2.Hash() || bm.Hash() == ewah.New().(*ewah.Ewah).Hash() {
+		t.Fatal("Bitmaps with different bits set should not have the same hash")
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// iterable is implemented by bitmaps that can iterate over the positions of their own bits
+type iterable interface {
+	All() iter.Seq[int64]
+}
+
+// All returns an iterator over the positions of the bits set in b, in ascending order:
+//
+//	for i := range bitmap.All(b) {
+//		...
+//	}
+//
+// Implementations with their own All method, like EWAH bitmaps and bitsets, are iterated with it.
+// Others are uncompressed into words first.
+func All(b Bitmap) iter.Seq[int64] {
+	b = evaluated(b)
+
+	if it, ok := b.(iterable); ok {
+		return it.All()
+	}
+
+	return func(yield func(int64) bool) {
+		for i, w := range words(b) {
+			for ; w != 0; w &= w - 1 {
+				if !yield(int64(i)*64 + int64(bits.TrailingZeros64(w))) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// backwardIterable is implemented by bitmaps that can iterate over the positions of their own bits
+// in descending order
+type backwardIterable interface {
+	Backward() iter.Seq[int64]
+}
+
+// Backward returns an iterator over the positions of the bits set in b, in descending order.
+// Implementations with their own Backward method, like EWAH bitmaps and bitsets, walk their words
+// from the end. Others can only be walked forward, so all the positions are collected with All
+// before the first one is returned.
+func Backward(b Bitmap) iter.Seq[int64] {
+	b = evaluated(b)
+
+	if it, ok := b.(backwardIterable); ok {
+		return it.Backward()
+	}
+
+	return func(yield func(int64) bool) {
+		var pos []int64
+		for i := range All(b) {
+			pos = append(pos, i)
+		}
+
+		for i := len(pos) - 1; i >= 0; i-- {
+			if !yield(pos[i]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package query
+
+import (
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"sort"
+)
+
+// Resolver returns the bitmap for the name of a term. The bitmap is not modified.
+type Resolver func(name string) (bitmap.Bitmap, error)
+
+type Evaluator struct {
+	Resolve Resolver
+
+	// Universe is the bitmap of all the rows, which NOT removes the rows of its operand from. If it's
+	// nil, NOT flips the bits of its operand up to its size, so rows past the last bit of the operand
+	// are left out. NOT as an operand of AND doesn't need the universe either way.
+	Universe bitmap.Bitmap
+}
+
+// Eval parses and evaluates an expression, without a universe for NOT.
+func Eval(expr string, resolve Resolver) (bitmap.Bitmap, error) {
+	n, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return (&Evaluator{Resolve: resolve}).Eval(n)
+}
+
+// Eval returns the rows matched by an expression, as a new bitmap. The operands of an AND are
+// intersected from the smallest to the largest, and evaluation stops as soon as the intersection is
+// empty. Terms are resolved before the other operands, so an empty term skips evaluating them.
+func (this *Evaluator) Eval(n Node) (bitmap.Bitmap, error) {
+	bm, owned, err := this.eval(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if !owned {
+		bm = bm.Clone()
+	}
+
+	return bm, nil
+}
+
+// eval returns the rows matched by n, and whether the bitmap is new rather than one returned by
+// Resolve, which must not be modified.
+func (this *Evaluator) eval(n Node) (bitmap.Bitmap, bool, error) {
+	switch x := n.(type) {
+	case *Term:
+		bm, err := this.Resolve(x.Name)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if bm == nil {
+			return nil, false, fmt.Errorf("query/Eval: %s resolved to a nil bitmap", x)
+		}
+
+		return bm, false, nil
+	case *Not:
+		return this.not(x.X)
+	case *And:
+		return this.and(x.X)
+	case *Or:
+		return this.or(x.X)
+	}
+
+	return nil, false, fmt.Errorf("query/Eval: unknown node %T", n)
+}
+
+func (this *Evaluator) not(n Node) (bitmap.Bitmap, bool, error) {
+	bm, owned, err := this.eval(n)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if this.Universe != nil {
+		return this.Universe.AndNot(bm), true, nil
+	}
+
+	if !owned {
+		bm = bm.Clone()
+	}
+
+	return bm.Not(), true, nil
+}
+
+func (this *Evaluator) and(x []Node) (bitmap.Bitmap, bool, error) {
+	var terms, others, negated []Node
+
+	for _, n := range x {
+		switch m := n.(type) {
+		case *Term:
+			terms = append(terms, m)
+		case *Not:
+			negated = append(negated, m.X)
+		default:
+			others = append(others, m)
+		}
+	}
+
+	// NOT a AND NOT b is NOT (a OR b)
+	if len(terms)+len(others) == 0 {
+		return this.not(&Or{X: negated})
+	}
+
+	type operand struct {
+		bm          bitmap.Bitmap
+		owned       bool
+		cardinality int64
+		bytes       int64
+	}
+
+	operands := make([]operand, 0, len(terms)+len(others))
+
+	for _, n := range append(terms, others...) {
+		bm, owned, err := this.eval(n)
+		if err != nil {
+			return nil, false, err
+		}
+
+		c := bm.Cardinality()
+		if c == 0 {
+			return bm, owned, nil
+		}
+
+		operands = append(operands, operand{bm, owned, c, sizeInBytes(bm)})
+	}
+
+	sort.SliceStable(operands, func(i, j int) bool {
+		if operands[i].cardinality != operands[j].cardinality {
+			return operands[i].cardinality < operands[j].cardinality
+		}
+
+		return operands[i].bytes < operands[j].bytes
+	})
+
+	acc, owned := operands[0].bm, operands[0].owned
+
+	for _, o := range operands[1:] {
+		if acc, owned = acc.And(o.bm), true; acc.Cardinality() == 0 {
+			return acc, owned, nil
+		}
+	}
+
+	for _, n := range negated {
+		bm, _, err := this.eval(n)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if acc, owned = acc.AndNot(bm), true; acc.Cardinality() == 0 {
+			return acc, owned, nil
+		}
+	}
+
+	return acc, owned, nil
+}
+
+func (this *Evaluator) or(x []Node) (bitmap.Bitmap, bool, error) {
+	var a []bitmap.Bitmap
+	var empty bitmap.Bitmap
+	var emptyOwned, owned bool
+
+	for _, n := range x {
+		bm, o, err := this.eval(n)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Empty operands don't add anything
+		if bm.Cardinality() == 0 {
+			empty, emptyOwned = bm, o
+			continue
+		}
+
+		a, owned = append(a, bm), o
+	}
+
+	switch len(a) {
+	case 0:
+		return empty, emptyOwned, nil
+	case 1:
+		return a[0], owned, nil
+	}
+
+	return a[0].Or(a[1:]...), true, nil
+}
+
+// sizeInBytes returns the compressed size of a bitmap if it reports one, or 0
+func sizeInBytes(bm bitmap.Bitmap) int64 {
+	if s, ok := bm.(interface{ SizeInBytes() int64 }); ok {
+		return s.SizeInBytes()
+	}
+
+	return 0
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+// Package query parses boolean expressions over named bitmaps and evaluates them with bitmap
+// operations. A term is any run of characters other than spaces and parentheses, or a double quoted
+// string, and is resolved to a bitmap by name. Terms are combined with AND, OR, NOT and parentheses,
+// where NOT binds tightest and AND binds tighter than OR:
+//
+//	(color:red OR color:blue) AND NOT status:deleted
+//
+// Keywords are case insensitive.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Node is a node of the syntax tree of an expression: a Term, Not, And or Or.
+type Node interface {
+	String() string
+}
+
+// Term is a bitmap looked up by name.
+type Term struct {
+	Name string
+}
+
+type Not struct {
+	X Node
+}
+
+type And struct {
+	X []Node
+}
+
+type Or struct {
+	X []Node
+}
+
+func (this *Term) String() string {
+	if this.Name == "" || strings.ContainsAny(this.Name, " \t\r\n()\"") || keyword(this.Name) != "" {
+		return strconv.Quote(this.Name)
+	}
+
+	return this.Name
+}
+
+func (this *Not) String() string {
+	return "NOT " + operand(this.X)
+}
+
+func (this *And) String() string {
+	return join(this.X, " AND ")
+}
+
+func (this *Or) String() string {
+	return join(this.X, " OR ")
+}
+
+func join(x []Node, op string) string {
+	s := make([]string, len(x))
+	for i, n := range x {
+		s[i] = operand(n)
+	}
+
+	return strings.Join(s, op)
+}
+
+// operand returns the string of a node, in parentheses if it's an AND or OR
+func operand(n Node) string {
+	switch n.(type) {
+	case *And, *Or:
+		return "(" + n.String() + ")"
+	}
+
+	return n.String()
+}
+
+// Parse parses an expression into its syntax tree. Chains of the same operator are flattened, so
+// "a AND b AND c" is a single And with three terms.
+func Parse(s string) (Node, error) {
+	p := &parser{s: s}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.kind != eof {
+		return nil, p.unexpected()
+	}
+
+	return n, nil
+}
+
+const (
+	eof = iota
+	term
+	lparen
+	rparen
+	and
+	or
+	not
+)
+
+type parser struct {
+	s string
+
+	// pos is the offset of the next token in s
+	pos int
+
+	// kind, text and offset describe the current token
+	kind   int
+	text   string
+	offset int
+}
+
+func keyword(s string) string {
+	switch k := strings.ToUpper(s); k {
+	case "AND", "OR", "NOT":
+		return k
+	}
+
+	return ""
+}
+
+// next reads the next token into kind, text and offset
+func (this *parser) next() error {
+	for this.pos < len(this.s) && strings.IndexByte(" \t\r\n", this.s[this.pos]) >= 0 {
+		this.pos += 1
+	}
+
+	this.offset = this.pos
+
+	if this.pos == len(this.s) {
+		this.kind, this.text = eof, ""
+		return nil
+	}
+
+	switch c := this.s[this.pos]; c {
+	case '(', ')':
+		this.kind, this.text = lparen, string(c)
+		if c == ')' {
+			this.kind = rparen
+		}
+		this.pos += 1
+		return nil
+	case '"':
+		q, err := strconv.QuotedPrefix(this.s[this.pos:])
+		if err != nil {
+			return fmt.Errorf("query/Parse: unterminated string at offset %d", this.pos)
+		}
+
+		this.kind = term
+		this.text, _ = strconv.Unquote(q)
+		this.pos += len(q)
+		return nil
+	}
+
+	end := this.pos
+	for end < len(this.s) && strings.IndexByte(" \t\r\n()\"", this.s[end]) < 0 {
+		end += 1
+	}
+
+	this.kind, this.text = term, this.s[this.pos:end]
+	this.pos = end
+
+	switch keyword(this.text) {
+	case "AND":
+		this.kind = and
+	case "OR":
+		this.kind = or
+	case "NOT":
+		this.kind = not
+	}
+
+	return nil
+}
+
+func (this *parser) unexpected() error {
+	if this.kind == eof {
+		return errors.New("query/Parse: unexpected end of expression")
+	}
+
+	return fmt.Errorf("query/Parse: unexpected %q at offset %d", this.s[this.offset:this.pos], this.offset)
+}
+
+func (this *parser) or() (Node, error) {
+	x, err := this.list(or, this.and)
+	if err != nil || len(x) == 1 {
+		return first(x), err
+	}
+
+	return &Or{X: x}, nil
+}
+
+func (this *parser) and() (Node, error) {
+	x, err := this.list(and, this.unary)
+	if err != nil || len(x) == 1 {
+		return first(x), err
+	}
+
+	return &And{X: x}, nil
+}
+
+func first(x []Node) Node {
+	if len(x) == 0 {
+		return nil
+	}
+
+	return x[0]
+}
+
+// list parses operands separated by op, flattening operands that are themselves chains of op
+func (this *parser) list(op int, operand func() (Node, error)) ([]Node, error) {
+	var x []Node
+
+	for {
+		n, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		switch m := n.(type) {
+		case *And:
+			if op == and {
+				x = append(x, m.X...)
+				n = nil
+			}
+		case *Or:
+			if op == or {
+				x = append(x, m.X...)
+				n = nil
+			}
+		}
+
+		if n != nil {
+			x = append(x, n)
+		}
+
+		if this.kind != op {
+			return x, nil
+		}
+
+		if err := this.next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (this *parser) unary() (Node, error) {
+	switch this.kind {
+	case not:
+		if err := this.next(); err != nil {
+			return nil, err
+		}
+
+		x, err := this.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Not{X: x}, nil
+	case lparen:
+		if err := this.next(); err != nil {
+			return nil, err
+		}
+
+		x, err := this.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if this.kind != rparen {
+			return nil, this.unexpected()
+		}
+
+		return x, this.next()
+	case term:
+		x := &Term{Name: this.text}
+		return x, this.next()
+	}
+
+	return nil, this.unexpected()
+}
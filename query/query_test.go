@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package query
+
+import (
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/ewah"
+	"math/rand"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr, ans string
+	}{
+		{"a", "a"},
+		{"a AND b and c", "a AND b AND c"},
+		{"a OR b AND c", "a OR (b AND c)"},
+		{"(a OR b) AND NOT c", "(a OR b) AND NOT c"},
+		{"((a OR (b OR c))) or d", "a OR b OR c OR d"},
+		{"NOT NOT a", "NOT NOT a"},
+		{"NOT (a AND b)", "NOT (a AND b)"},
+		{`"color:light blue" OR "and"`, `"color:light blue" OR "and"`},
+		{"color:red AND(status:new)", "color:red AND status:new"},
+	}
+
+	for _, test := range tests {
+		n, err := Parse(test.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", test.expr, err)
+		}
+
+		if n.String() != test.ans {
+			t.Fatalf("Parse(%q) = %q, should be %q", test.expr, n.String(), test.ans)
+		}
+	}
+
+	for _, expr := range []string{"", "a AND", "a b", "(a", "a)", "NOT", `"a`, "AND a", "a OR OR b"} {
+		if n, err := Parse(expr); err == nil {
+			t.Fatalf("Parse(%q) = %q, should fail", expr, n)
+		}
+	}
+}
+
+const rows = 1000
+
+// newBitmaps returns bitmaps for terms a to e, and their bits
+func newBitmaps() (map[string]bitmap.Bitmap, map[string][]bool) {
+	r := rand.New(rand.NewSource(1))
+	bitmaps := make(map[string]bitmap.Bitmap)
+	bits := make(map[string][]bool)
+
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		bm, b := ewah.New(), make([]bool, rows)
+
+		// Terms get sparser and sparser, and e is empty
+		for row := 0; row < rows && i < 4; row++ {
+			if r.Intn(1<<uint(i)) == 0 {
+				bm.Set(int64(row))
+				b[row] = true
+			}
+		}
+
+		bitmaps[name], bits[name] = bm, b
+	}
+
+	return bitmaps, bits
+}
+
+// match evaluates an expression for one row without bitmaps
+func match(n Node, bits map[string][]bool, row int) bool {
+	switch x := n.(type) {
+	case *Term:
+		return bits[x.Name][row]
+	case *Not:
+		return !match(x.X, bits, row)
+	case *And:
+		for _, m := range x.X {
+			if !match(m, bits, row) {
+				return false
+			}
+		}
+		return true
+	case *Or:
+		for _, m := range x.X {
+			if match(m, bits, row) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func TestEval(t *testing.T) {
+	bitmaps, bits := newBitmaps()
+
+	universe := ewah.New()
+	for row := int64(0); row < rows; row++ {
+		universe.Set(row)
+	}
+
+	var resolved []string
+	e := &Evaluator{
+		Resolve: func(name string) (bitmap.Bitmap, error) {
+			resolved = append(resolved, name)
+			if bm, ok := bitmaps[name]; ok {
+				return bm.(*ewah.Ewah).Freeze(), nil
+			}
+			return nil, fmt.Errorf("unknown term %s", name)
+		},
+		Universe: universe,
+	}
+
+	for _, expr := range []string{
+		"a",
+		"NOT a",
+		"a AND b",
+		"d AND a AND NOT c",
+		"(a OR b) AND NOT (c OR d)",
+		"NOT b AND NOT d",
+		"e OR c",
+		"e OR e",
+		"a AND e",
+		"NOT (a AND b) OR (c AND NOT NOT d)",
+		"a AND (NOT b OR c) AND NOT d",
+	} {
+		n, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bm, err := e.Eval(n)
+		if err != nil {
+			t.Fatalf("Eval(%q): %v", expr, err)
+		}
+
+		count := int64(0)
+		for row := 0; row < rows; row++ {
+			if bm.Get(int64(row)) != match(n, bits, row) {
+				t.Fatalf("Eval(%q): row %d is %t", expr, row, bm.Get(int64(row)))
+			}
+
+			if match(n, bits, row) {
+				count += 1
+			}
+		}
+
+		if bm.Cardinality() != count {
+			t.Fatalf("Eval(%q): cardinality %d != %d", expr, bm.Cardinality(), count)
+		}
+
+		// The result is a new bitmap, so it can be modified
+		bm.Set(rows)
+	}
+
+	// An empty term answers an AND without evaluating the other operands
+	resolved = nil
+	n, _ := Parse("(a OR b) AND e AND NOT c")
+	if bm, err := e.Eval(n); err != nil || bm.Cardinality() != 0 {
+		t.Fatalf("Eval(%q) should be empty", n)
+	}
+
+	if len(resolved) != 1 || resolved[0] != "e" {
+		t.Fatalf("Eval(%q) resolved %v, should only resolve e", n, resolved)
+	}
+
+	if _, err := Eval("a AND unknown", e.Resolve); err == nil {
+		t.Fatal("Eval should fail on an unknown term")
+	}
+}
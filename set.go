@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap
+
+import (
+	"iter"
+)
+
+// Integer is any integer type, which Set can hold.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Set is a set of integers of type T stored in a bitmap, so document or row IDs can be used without
+// converting them to int64 everywhere:
+//
+//	docs := bitmap.NewSet[uint32](ewah.New())
+//	docs.Set(10).Set(1000)
+//	for id := range docs.All() {
+//		...
+//	}
+//
+// Only values from 0 to math.MaxInt64 can be stored.
+type Set[T Integer] struct {
+	bm Bitmap
+}
+
+// NewSet returns a set stored in b. The set and b share the same bits.
+func NewSet[T Integer](b Bitmap) *Set[T] {
+	return &Set[T]{bm: b}
+}
+
+// Bitmap returns the bitmap the set is stored in.
+func (this *Set[T]) Bitmap() Bitmap {
+	return this.bm
+}
+
+// Set adds v to the set. It returns nil if v is negative, too large, or can't be set in the bitmap,
+// like a value smaller than the largest one in an EWAH bitmap.
+func (this *Set[T]) Set(v T) *Set[T] {
+	i := int64(v)
+	if i < 0 || this.bm.Set(i) == nil {
+		return nil
+	}
+
+	return this
+}
+
+// Get returns true if v is in the set.
+func (this *Set[T]) Get(v T) bool {
+	i := int64(v)
+	return i >= 0 && this.bm.Get(i)
+}
+
+// Cardinality returns the number of values in the set.
+func (this *Set[T]) Cardinality() int64 {
+	return this.bm.Cardinality()
+}
+
+func (this *Set[T]) Clone() *Set[T] {
+	return &Set[T]{bm: this.bm.Clone()}
+}
+
+func (this *Set[T]) Equal(other *Set[T]) bool {
+	return this.bm.Equal(other.bm)
+}
+
+func (this *Set[T]) And(a ...*Set[T]) *Set[T] {
+	return this.apply(this.bm.And, a)
+}
+
+func (this *Set[T]) Or(a ...*Set[T]) *Set[T] {
+	return this.apply(this.bm.Or, a)
+}
+
+func (this *Set[T]) AndNot(a ...*Set[T]) *Set[T] {
+	return this.apply(this.bm.AndNot, a)
+}
+
+func (this *Set[T]) Xor(a ...*Set[T]) *Set[T] {
+	return this.apply(this.bm.Xor, a)
+}
+
+func (this *Set[T]) apply(op func(...Bitmap) Bitmap, a []*Set[T]) *Set[T] {
+	b := make([]Bitmap, len(a))
+	for i, s := range a {
+		b[i] = s.bm
+	}
+
+	ans := op(b...)
+	if ans == nil {
+		return nil
+	}
+
+	return &Set[T]{bm: ans}
+}
+
+// All returns an iterator over the values in the set, in ascending order. Bits of the bitmap past
+// the largest value of T are not values of the set, and end the iteration.
+func (this *Set[T]) All() iter.Seq[T] {
+	return values[T](All(this.bm))
+}
+
+// Backward returns an iterator over the values in the set, in descending order.
+func (this *Set[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := range Backward(this.bm) {
+			if v := T(i); int64(v) == i && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func values[T Integer](positions iter.Seq[int64]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := range positions {
+			v := T(i)
+			if int64(v) != i || !yield(v) {
+				return
+			}
+		}
+	}
+}
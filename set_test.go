@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap_test
+
+import (
+	"fmt"
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/ewah"
+	"testing"
+)
+
+func TestGenericSet(t *testing.T) {
+	docs := bitmap.NewSet[uint32](ewah.New())
+	for _, v := range []uint32{1, 64, 1000, 1 << 20} {
+		if docs.Set(v) == nil || !docs.Get(v) {
+			t.Fatalf("Problem setting %d", v)
+		}
+	}
+
+	if docs.Set(10) != nil || docs.Get(10) || docs.Cardinality() != 4 {
+		t.Fatal("Setting a value out of order in an EWAH bitmap should fail")
+	}
+
+	var all, backward []uint32
+	for v := range docs.All() {
+		all = append(all, v)
+	}
+	for v := range docs.Backward() {
+		backward = append(backward, v)
+	}
+
+	if fmt.Sprint(all) != "[1 64 1000 1048576]" || fmt.Sprint(backward) != "[1048576 1000 64 1]" {
+		t.Fatalf("All() = %v, Backward() = %v", all, backward)
+	}
+
+	rows := bitmap.NewSet[uint64](bitset.New())
+	if rows.Set(1<<63) != nil || rows.Get(1<<63) {
+		t.Fatal("Values past math.MaxInt64 should not be set")
+	}
+
+	small := bitmap.NewSet[int8](bitset.New().Set(5).Set(100).Set(300))
+	if small.Set(-1) != nil || small.Get(-1) {
+		t.Fatal("Negative values should not be set")
+	}
+
+	// 300 doesn't fit in an int8
+	all8 := []int8{}
+	for v := range small.All() {
+		all8 = append(all8, v)
+	}
+	if fmt.Sprint(all8) != "[5 100]" {
+		t.Fatalf("All() = %v", all8)
+	}
+
+	evens := bitmap.NewSet[uint32](ewah.New().Set(2).Set(64).Set(1000))
+	if ans := docs.And(evens); ans.Cardinality() != 2 || !ans.Get(64) || !ans.Get(1000) {
+		t.Fatal("And of sets should keep the values in both")
+	}
+
+	if ans := docs.Or(evens).AndNot(evens).Xor(docs); ans.Cardinality() != 2 || !ans.Get(64) || !ans.Get(1000) {
+		t.Fatal("Or, AndNot and Xor of sets")
+	}
+
+	// Lazy expressions are evaluated before iterating
+	n := 0
+	for i := range bitmap.All(bitmap.Lazy(docs.Bitmap()).And(evens.Bitmap())) {
+		if i != 64 && i != 1000 {
+			t.Fatalf("Unexpected position %d", i)
+		}
+		n++
+	}
+
+	if n != 2 {
+		t.Fatalf("All() of a lazy And returned %d positions", n)
+	}
+
+	// Stopping early in the middle of a run of 1's
+	dense := bitmap.NewSet[uint16](ewah.New())
+	for v := uint16(100); v < 1000; v++ {
+		dense.Set(v)
+	}
+
+	var last []uint16
+	for v := range dense.Backward() {
+		if last = append(last, v); len(last) == 3 {
+			break
+		}
+	}
+
+	if fmt.Sprint(last) != "[999 998 997]" {
+		t.Fatalf("Backward() = %v", last)
+	}
+}
+
+// forwardOnly is a bitmap without its own iterators, so Backward has to collect its positions
+type forwardOnly struct {
+	bitmap.Bitmap
+}
+
+func TestBackward(t *testing.T) {
+	positions := []int64{0, 1, 63, 64, 65, 1000, 2000, 2001, 100000}
+	bms := map[string]bitmap.Bitmap{
+		"ewah":    ewah.New(),
+		"bitset":  bitset.New(),
+		"generic": forwardOnly{bitset.New()},
+	}
+
+	for name, bm := range bms {
+		for _, i := range positions {
+			bm.Set(i)
+		}
+
+		var back []int64
+		for i := range bitmap.Backward(bm) {
+			back = append(back, i)
+		}
+
+		if len(back) != len(positions) {
+			t.Fatalf("%s: Backward() = %v", name, back)
+		}
+
+		for k, i := range back {
+			if i != positions[len(positions)-1-k] {
+				t.Fatalf("%s: Backward() = %v", name, back)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap
+
+import (
+	"math"
+)
+
+// Cardinalities is implemented by bitmaps that can count the bits of the result of a bitwise
+// operation without creating the result. Jaccard, Hamming and Cosine use it when it's available.
+type Cardinalities interface {
+	AndCardinality(Bitmap) int64
+	OrCardinality(Bitmap) int64
+	AndNotCardinality(Bitmap) int64
+	XorCardinality(Bitmap) int64
+}
+
+// AndCardinality returns the number of bits set in both a and b. It uses the Cardinalities of
+// either bitmap if it has them, since And is symmetric, and only creates a.And(b) otherwise.
+func AndCardinality(a, b Bitmap) int64 {
+	if c, ok := a.(Cardinalities); ok {
+		return c.AndCardinality(b)
+	}
+
+	if c, ok := b.(Cardinalities); ok {
+		return c.AndCardinality(a)
+	}
+
+	return a.And(b).Cardinality()
+}
+
+// OrCardinality returns the number of bits set in either a or b, like AndCardinality.
+func OrCardinality(a, b Bitmap) int64 {
+	if c, ok := a.(Cardinalities); ok {
+		return c.OrCardinality(b)
+	}
+
+	if c, ok := b.(Cardinalities); ok {
+		return c.OrCardinality(a)
+	}
+
+	return a.Or(b).Cardinality()
+}
+
+// Jaccard returns the Jaccard similarity of a and b, the number of bits set in both divided by the
+// number of bits set in either. Two empty bitmaps are identical, so their similarity is 1.
+func Jaccard(a, b Bitmap) float64 {
+	union := OrCardinality(a, b)
+	if union == 0 {
+		return 1
+	}
+
+	return float64(AndCardinality(a, b)) / float64(union)
+}
+
+// Hamming returns the Hamming distance between a and b, the number of bits set in one but not the
+// other.
+func Hamming(a, b Bitmap) int64 {
+	if c, ok := a.(Cardinalities); ok {
+		return c.XorCardinality(b)
+	}
+
+	if c, ok := b.(Cardinalities); ok {
+		return c.XorCardinality(a)
+	}
+
+	return a.Xor(b).Cardinality()
+}
+
+// Cosine returns the cosine similarity of a and b, the number of bits set in both divided by the
+// geometric mean of their cardinalities. It's 0 if either bitmap is empty.
+func Cosine(a, b Bitmap) float64 {
+	ca, cb := a.Cardinality(), b.Cardinality()
+	if ca == 0 || cb == 0 {
+		return 0
+	}
+
+	return float64(AndCardinality(a, b)) / math.Sqrt(float64(ca)*float64(cb))
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap_test
+
+import (
+	"github.com/reducedb/bitmap"
+	"github.com/reducedb/bitmap/bitset"
+	"github.com/reducedb/bitmap/ewah"
+	"math"
+	"testing"
+)
+
+// uncounted is a bitmap without Cardinalities, which fails the test if the result of a bitwise
+// operation is created
+type uncounted struct {
+	bitmap.Bitmap
+	t *testing.T
+}
+
+func (this uncounted) And(a ...bitmap.Bitmap) bitmap.Bitmap {
+	this.t.Fatal("And should not be called")
+	return nil
+}
+
+func (this uncounted) Or(a ...bitmap.Bitmap) bitmap.Bitmap {
+	this.t.Fatal("Or should not be called")
+	return nil
+}
+
+func (this uncounted) Xor(a ...bitmap.Bitmap) bitmap.Bitmap {
+	this.t.Fatal("Xor should not be called")
+	return nil
+}
+
+func TestSimilarity(t *testing.T) {
+	bm1 := ewah.New()
+	bm2 := bitset.New()
+
+	// 3 bits in common, 1 only in bm1 and 2 only in bm2
+	for _, i := range []int64{1, 100, 1000, 5000} {
+		bm1.Set(i)
+	}
+
+	for _, i := range []int64{100, 1000, 5000, 6000, 100000} {
+		bm2.Set(i)
+	}
+
+	pairs := map[string][2]bitmap.Bitmap{
+		"EWAH and bitset":     {bm1, bm2},
+		"frozen":              {bm1.(*ewah.Ewah).Freeze(), bm2.(*bitset.Bitset).Freeze()},
+		"expressions":         {bitmap.Lazy(bm1).Or(bm1), bitmap.Lazy(bm2)},
+		"without cardinality": {uncounted{bm1, t}, bm2},
+	}
+
+	for name, p := range pairs {
+		if j := bitmap.Jaccard(p[0], p[1]); j != 3.0/6.0 {
+			t.Fatalf("%s: Jaccard = %f, expected %f", name, j, 3.0/6.0)
+		}
+
+		if a, o := bitmap.AndCardinality(p[0], p[1]), bitmap.OrCardinality(p[1], p[0]); a != 3 || o != 6 {
+			t.Fatalf("%s: AndCardinality = %d, OrCardinality = %d, expected 3 and 6", name, a, o)
+		}
+
+		if h := bitmap.Hamming(p[0], p[1]); h != 3 {
+			t.Fatalf("%s: Hamming = %d, expected 3", name, h)
+		}
+
+		if c := bitmap.Cosine(p[0], p[1]); math.Abs(c-3/math.Sqrt(20)) > 1e-9 {
+			t.Fatalf("%s: Cosine = %f, expected %f", name, c, 3/math.Sqrt(20))
+		}
+	}
+
+	if bitmap.Jaccard(bm1, bm1) != 1 || bitmap.Hamming(bm1, bm1.Clone()) != 0 || bitmap.Cosine(bm1, ewah.New()) != 0 {
+		t.Fatal("Similarity of a bitmap to itself or to an empty bitmap is wrong")
+	}
+
+	if bitmap.Jaccard(ewah.New(), ewah.New()) != 1 {
+		t.Fatal("Jaccard similarity of two empty bitmaps should be 1")
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2013 Zhen, LLC. http://zhen.io. All rights reserved.
+ * Use of this source code is governed by the Apache 2.0 license.
+ *
+ */
+
+package bitmap
+
+import (
+	"fmt"
+)
+
+// Stats describes how a bitmap is stored. Implementations that are not compressed report all of
+// their words as literal words.
+type Stats struct {
+	// SizeInBits is the size of the uncompressed bitmap, in bits
+	SizeInBits int64
+
+	// SizeInWords is the number of 64-bit words used to store the bitmap
+	SizeInWords int64
+
+	// SizeInBytes is the number of bytes used to store the bitmap
+	SizeInBytes int64
+
+	// Cardinality is the number of bits set
+	Cardinality int64
+
+	// MarkerWords is the number of marker (running length) words
+	MarkerWords int64
+
+	// LiteralWords is the number of literal words
+	LiteralWords int64
+
+	// RunLengths is a histogram of the running lengths, in words. RunLengths[i] is the number of
+	// runs that are between 2^i and 2^(i+1)-1 words long.
+	RunLengths []int64
+
+	// CompressionRatio is the uncompressed size divided by the stored size. A ratio below 1 means
+	// the bitmap takes more space than a plain bitset would.
+	CompressionRatio float64
+}
+
+// UncompressedWords returns the number of words needed to store the bitmap uncompressed.
+func (this Stats) UncompressedWords() int64 {
+	return (this.SizeInBits + 63) / 64
+}
+
+func (this Stats) String() string {
+	return fmt.Sprintf("sizeInBits = %d, sizeInWords = %d, sizeInBytes = %d, cardinality = %d, markerWords = %d, literalWords = %d, compressionRatio = %.2f",
+		this.SizeInBits, this.SizeInWords, this.SizeInBytes, this.Cardinality, this.MarkerWords, this.LiteralWords, this.CompressionRatio)
+}